@@ -8,7 +8,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 )
 
 func CreateClient(config *ClientConfig) (*ClientConfig, error) {
@@ -23,6 +26,14 @@ func CreateClient(config *ClientConfig) (*ClientConfig, error) {
 		OAuthAccessScopes:        []string{},
 		OAuthClientAllowedScopes: []string{},
 		ClientId:                 "",
+		Host:                     "",
+		OAuthCallbackURL:         "",
+		RetryPolicy:              config.RetryPolicy,
+		HTTPClient:               config.HTTPClient,
+		Transport:                config.Transport,
+		Middleware:               config.Middleware,
+		Logger:                   config.Logger,
+		Instrumentation:          config.Instrumentation,
 	}
 
 	if config.ApiToken != "" {
@@ -119,38 +130,125 @@ func CreateClient(config *ClientConfig) (*ClientConfig, error) {
 		clientConfig.ClientId = os.Getenv("JUPYTERHUB_CLIENT_ID")
 	}
 
+	if config.Host != "" {
+		clientConfig.Host = config.Host
+	} else {
+		clientConfig.Host = os.Getenv("JUPYTERHUB_HOST")
+	}
+
+	if config.OAuthCallbackURL != "" {
+		clientConfig.OAuthCallbackURL = config.OAuthCallbackURL
+	} else {
+		clientConfig.OAuthCallbackURL = os.Getenv("JUPYTERHUB_OAUTH_CALLBACK_URL")
+	}
+
+	clientConfig.httpClient = clientConfig.buildHTTPClient()
+
 	return &clientConfig, nil
 }
 
-func (c *ClientConfig) Request(ctx context.Context, method string, path string, contentType string, requestBody []byte) ([]byte, error) {
-	url := fmt.Sprintf("%s/%s", c.ApiURL, path)
-	client := &http.Client{}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(requestBody))
-	req.WithContext(ctx)
+func (c *ClientConfig) Request(ctx context.Context, method string, path string, contentType string, requestBody []byte, opts ...RequestOption) ([]byte, error) {
+	_, body, err := c.request(ctx, method, path, contentType, requestBody, opts)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.ApiToken))
-	req.Header.Set("Content-Type", contentType)
-	resp, err := client.Do(req)
+	return body, nil
+}
+
+// request performs Request and also returns the status code of the
+// successful response.
+func (c *ClientConfig) request(ctx context.Context, method string, path string, contentType string, requestBody []byte, opts []RequestOption) (int, []byte, error) {
+	options := newRequestOptions(c, opts)
+	if options.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+		defer cancel()
+	}
+
+	resp, body, err := c.do(ctx, method, path, contentType, requestBody, options, false)
 	if err != nil {
+		return 0, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// requestStream performs a GET like Request but returns the open response
+// body of a successful response for the caller to consume and close.
+func (c *ClientConfig) requestStream(ctx context.Context, path string, opts []RequestOption) (io.ReadCloser, error) {
+	options := newRequestOptions(c, opts)
+	cancel := context.CancelFunc(func() {})
+	if options.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, options.timeout)
+	}
+
+	resp, _, err := c.do(ctx, http.MethodGet, path, "application/json", nil, options, true)
+	if err != nil {
+		cancel()
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return &cancelReadCloser{ReadCloser: resp.Body, cancel: cancel}, nil
+}
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return nil, fmt.Errorf("response returned status code of %d instead of 2XX", resp.StatusCode)
+// do sends the request, retrying according to the request's RetryPolicy. The
+// response body is read into memory unless stream is set and the response
+// was successful.
+func (c *ClientConfig) do(ctx context.Context, method string, path string, contentType string, requestBody []byte, options *requestOptions, stream bool) (resp *http.Response, body []byte, err error) {
+	url := fmt.Sprintf("%s/%s", c.ApiURL, path)
+	client := c.client()
+	policy := options.retryPolicy
+	maxAttempts := policy.maxAttempts()
+	info := requestInfo(method, path, options)
+	if c.Instrumentation != nil {
+		ctx = c.Instrumentation.Start(ctx, info)
+	}
+	start := time.Now()
+	attempt := 1
+	defer func() {
+		latency := time.Since(start)
+		if c.Instrumentation != nil {
+			c.Instrumentation.Finish(ctx, info, RequestResult{StatusCode: responseStatus(resp, err), Duration: latency, Attempts: attempt, Err: err})
+		}
+		c.logRequest(ctx, method, path, contentType, requestBody, options, resp, body, attempt, latency, err)
+	}()
+	for ; ; attempt++ {
+		resp, err = c.send(ctx, client, method, url, contentType, requestBody, options)
+		success := err == nil && resp.StatusCode >= 200 && resp.StatusCode < 300
+
+		body = nil
+		if err == nil && !(stream && success) {
+			body, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		if err != nil {
+			if attempt < maxAttempts && policy.retryable(method, options.idempotent, 0, err) && policy.wait(ctx, attempt, nil) {
+				continue
+			}
+			return nil, nil, newRequestError(method, path, attempt, err)
+		}
+
+		if !success {
+			if attempt < maxAttempts && policy.retryable(method, options.idempotent, resp.StatusCode, nil) && policy.wait(ctx, attempt, resp) {
+				continue
+			}
+			apiErr := newAPIError(method, path, resp, body)
+			apiErr.Attempts = attempt
+			return nil, nil, apiErr
+		}
+		return resp, body, nil
 	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+func (c *ClientConfig) send(ctx context.Context, client *http.Client, method string, url string, contentType string, requestBody []byte, options *requestOptions) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(requestBody))
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	req.Header = options.requestHeader(contentType)
+	return client.Do(req)
 }
 
-func (c *ClientConfig) GetInfo(ctx context.Context) (*InfoResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, "info", "application/json", nil)
+func (c *ClientConfig) GetInfo(ctx context.Context, opts ...RequestOption) (*InfoResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, "info", "application/json", nil, operation(opts, "GetInfo", "info")...)
 	if err != nil {
 		return nil, err
 	}
@@ -162,8 +260,8 @@ func (c *ClientConfig) GetInfo(ctx context.Context) (*InfoResponse, error) {
 	return &result, nil
 }
 
-func (c *ClientConfig) GetVersion(ctx context.Context) (*VersionResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, "", "application/json", nil)
+func (c *ClientConfig) GetVersion(ctx context.Context, opts ...RequestOption) (*VersionResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, "", "application/json", nil, operation(opts, "GetVersion", "")...)
 	if err != nil {
 		return nil, err
 	}
@@ -175,8 +273,8 @@ func (c *ClientConfig) GetVersion(ctx context.Context) (*VersionResponse, error)
 	return &result, nil
 }
 
-func (c *ClientConfig) GetCurrentUser(ctx context.Context) (*CurrentUserResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, "user", "application/json", nil)
+func (c *ClientConfig) GetCurrentUser(ctx context.Context, opts ...RequestOption) (*CurrentUserResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, "user", "application/json", nil, operation(opts, "GetCurrentUser", "user")...)
 	if err != nil {
 		return nil, err
 	}
@@ -188,13 +286,16 @@ func (c *ClientConfig) GetCurrentUser(ctx context.Context) (*CurrentUserResponse
 	return &result, nil
 }
 
-func (c *ClientConfig) ListUsers(ctx context.Context, options *ListUsersParams) (*ListUsersResponse, error) {
-	url := "users"
+func (c *ClientConfig) ListUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersResponse, error) {
+	var query url.Values
 	if options != nil {
-		url = fmt.Sprintf("%s?%s", url, options.Encode())
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
 	}
 
-	data, err := c.Request(ctx, http.MethodGet, url, "application/json", nil)
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "users"), "application/json", nil, operation(opts, "ListUsers", "users")...)
 	if err != nil {
 		return nil, err
 	}
@@ -206,12 +307,59 @@ func (c *ClientConfig) ListUsers(ctx context.Context, options *ListUsersParams)
 	return &result, nil
 }
 
-func (c *ClientConfig) CreateUsers(ctx context.Context, options *CreateUsersBody) (*ListUsersResponse, error) {
+func (c *ClientConfig) ListUsersPaginated(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersPage, error) {
+	var query url.Values
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
+	}
+
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "users"), "application/json", nil, paginated(operation(opts, "ListUsersPaginated", "users"))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ListUsersPage
+	result.Pagination, err = decodePage(data, &result.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ClientConfig) ListAllUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (ListUsersResponse, error) {
+	params := ListUsersParams{}
+	if options != nil {
+		params = *options
+	}
+
+	result := ListUsersResponse{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := c.ListUsersPaginated(ctx, &params, opts...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page.Items...)
+
+		offset, limit, ok := nextPage(page.Pagination, params.Offset, params.Limit, len(page.Items))
+		if !ok {
+			return result, nil
+		}
+		params.Offset, params.Limit = offset, limit
+	}
+}
+
+func (c *ClientConfig) CreateUsers(ctx context.Context, options *CreateUsersBody, opts ...RequestOption) (*ListUsersResponse, error) {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return nil, err
 	}
-	data, err := c.Request(ctx, http.MethodPost, "users", "application/json", body)
+	data, err := c.Request(ctx, http.MethodPost, "users", "application/json", body, operation(opts, "CreateUsers", "users")...)
 	if err != nil {
 		return nil, err
 	}
@@ -223,8 +371,8 @@ func (c *ClientConfig) CreateUsers(ctx context.Context, options *CreateUsersBody
 	return &result, nil
 }
 
-func (c *ClientConfig) GetUser(ctx context.Context, username string) (*GetUserResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("users/%s", username), "application/json", nil)
+func (c *ClientConfig) GetUser(ctx context.Context, username string, opts ...RequestOption) (*GetUserResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("users", username), "application/json", nil, operation(opts, "GetUser", "users/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -236,8 +384,8 @@ func (c *ClientConfig) GetUser(ctx context.Context, username string) (*GetUserRe
 	return &result, nil
 }
 
-func (c *ClientConfig) CreateUser(ctx context.Context, username string) (*CreateUserResponse, error) {
-	data, err := c.Request(ctx, http.MethodPost, fmt.Sprintf("users/%s", username), "application/json", nil)
+func (c *ClientConfig) CreateUser(ctx context.Context, username string, opts ...RequestOption) (*CreateUserResponse, error) {
+	data, err := c.Request(ctx, http.MethodPost, apiPath("users", username), "application/json", nil, operation(opts, "CreateUser", "users/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -249,21 +397,21 @@ func (c *ClientConfig) CreateUser(ctx context.Context, username string) (*Create
 	return &result, nil
 }
 
-func (c *ClientConfig) DeleteUser(ctx context.Context, username string) error {
-	_, err := c.Request(ctx, http.MethodDelete, fmt.Sprintf("users/%s", username), "application/json", nil)
+func (c *ClientConfig) DeleteUser(ctx context.Context, username string, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodDelete, apiPath("users", username), "application/json", nil, operation(opts, "DeleteUser", "users/{name}")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) UpdateUser(ctx context.Context, username string, options *UpdateUserBody) (*UpdateUserResponse, error) {
+func (c *ClientConfig) UpdateUser(ctx context.Context, username string, options *UpdateUserBody, opts ...RequestOption) (*UpdateUserResponse, error) {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return nil, err
 	}
 
-	data, err := c.Request(ctx, http.MethodPatch, fmt.Sprintf("users/%s", username), "application/json", body)
+	data, err := c.Request(ctx, http.MethodPatch, apiPath("users", username), "application/json", body, operation(opts, "UpdateUser", "users/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -275,63 +423,169 @@ func (c *ClientConfig) UpdateUser(ctx context.Context, username string, options
 	return &result, nil
 }
 
-func (c *ClientConfig) NotifyUserActivity(ctx context.Context, username string, options *UserActivityBody) error {
+// ModifyUser fetches username, applies mutate to a copy of it and PATCHes
+// only the fields that mutate changed. If nothing changed no PATCH is sent and
+// the fetched user is returned.
+func (c *ClientConfig) ModifyUser(ctx context.Context, username string, mutate func(user *JupyterHubUser) error, opts ...RequestOption) (*UpdateUserResponse, error) {
+	current, err := c.GetUser(ctx, username, opts...)
+	if err != nil {
+		return nil, err
+	}
+	originalAuthState, err := json.Marshal(current.AuthState)
+	if err != nil {
+		return nil, err
+	}
+
+	user := JupyterHubUser(*current)
+	if err := mutate(&user); err != nil {
+		return nil, err
+	}
+
+	patch := &UpdateUserBody{}
+	if user.Name != current.Name {
+		patch.SetName(user.Name)
+	}
+	if user.Admin != current.Admin {
+		patch.SetAdmin(user.Admin)
+	}
+	authState, err := json.Marshal(user.AuthState)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(authState, originalAuthState) {
+		patch.SetAuthState(user.AuthState)
+	}
+
+	if patch.IsEmpty() {
+		result := UpdateUserResponse(*current)
+		return &result, nil
+	}
+	return c.UpdateUser(ctx, username, patch, opts...)
+}
+
+func (c *ClientConfig) NotifyUserActivity(ctx context.Context, username string, options *UserActivityBody, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.Request(ctx, http.MethodPost, fmt.Sprintf("users/%s/activity", username), "application/json", body)
+	_, err = c.Request(ctx, http.MethodPost, apiPath("users", username, "activity"), "application/json", body, operation(opts, "NotifyUserActivity", "users/{name}/activity")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) StartUserServer(ctx context.Context, username string, options interface{}) error {
+func (c *ClientConfig) StartUserServer(ctx context.Context, username string, options interface{}, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.Request(ctx, http.MethodPost, fmt.Sprintf("users/%s/server", username), "application/json", body)
+	_, err = c.Request(ctx, http.MethodPost, apiPath("users", username, "server"), "application/json", body, operation(opts, "StartUserServer", "users/{name}/server")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) StopUserServer(ctx context.Context, username string) error {
-	_, err := c.Request(ctx, http.MethodDelete, fmt.Sprintf("users/%s/server", username), "application/json", nil)
+func (c *ClientConfig) StopUserServer(ctx context.Context, username string, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodDelete, apiPath("users", username, "server"), "application/json", nil, operation(opts, "StopUserServer", "users/{name}/server")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) StartUserNamedServer(ctx context.Context, username string, serverName string, options interface{}) error {
+func (c *ClientConfig) StartUserNamedServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
 
-	_, err = c.Request(ctx, http.MethodPost, fmt.Sprintf("users/%s/servers/%s", username, serverName), "application/json", body)
+	_, err = c.Request(ctx, http.MethodPost, apiPath("users", username, "servers", serverName), "application/json", body, operation(opts, "StartUserNamedServer", "users/{name}/servers/{server_name}")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) StopUserNamedServer(ctx context.Context, username string, serverName string) error {
-	_, err := c.Request(ctx, http.MethodDelete, fmt.Sprintf("users/%s/servers/%s", username, serverName), "application/json", nil)
+// StartServer starts the default server of username, or the named server
+// serverName when it is set, reporting whether the hub finished the spawn or
+// left it pending.
+func (c *ClientConfig) StartServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) (StartResult, error) {
+	body, err := json.Marshal(options)
+	if err != nil {
+		return 0, err
+	}
+
+	status, _, err := c.request(ctx, http.MethodPost, serverPath(username, serverName), "application/json", body, operation(opts, "StartServer", serverTemplate(serverName)))
+	if err != nil {
+		return 0, err
+	}
+	if status == http.StatusAccepted {
+		return StartResultPending, nil
+	}
+	return StartResultStarted, nil
+}
+
+func (c *ClientConfig) StopUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodDelete, apiPath("users", username, "servers", serverName), "application/json", nil, operation(opts, "StopUserNamedServer", "users/{name}/servers/{server_name}")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) ListUserTokens(ctx context.Context, username string) (*ListTokenResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("users/%s/tokens", username), "application/json", nil)
+// StopServer stops the default server of username, or the named server
+// serverName when it is set. With remove the named server is also deleted
+// from the hub once stopped.
+func (c *ClientConfig) StopServer(ctx context.Context, username string, serverName string, remove bool, opts ...RequestOption) (StopResult, error) {
+	var body []byte
+	if remove {
+		if serverName == "" {
+			return 0, errors.New("only named servers can be removed")
+		}
+		body = []byte(`{"remove": true}`)
+	}
+
+	// JupyterHub answers 204 whether or not the server was running, so a
+	// 204 is only reported as StopResultAlreadyStopped when the hub listed
+	// the server as stopped beforehand.
+	alreadyStopped := c.serverStopped(ctx, username, serverName, opts)
+
+	status, _, err := c.request(ctx, http.MethodDelete, serverPath(username, serverName), "application/json", body, operation(opts, "StopServer", serverTemplate(serverName)))
+	if err != nil {
+		return 0, err
+	}
+	if status == http.StatusAccepted {
+		return StopResultPending, nil
+	}
+	if alreadyStopped {
+		return StopResultAlreadyStopped, nil
+	}
+	return StopResultStopped, nil
+}
+
+// serverStopped reports whether the hub lists the server as stopped. It is
+// false when that is unknown because the token may not read the user or
+// their servers, which does not prevent stopping the server.
+func (c *ClientConfig) serverStopped(ctx context.Context, username string, serverName string, opts []RequestOption) bool {
+	user, err := c.GetUser(ctx, username, opts...)
+	if err != nil || user.Servers == nil {
+		return false
+	}
+	server, ok := user.Servers[serverName]
+	return !ok || server.Stopped || (!server.Ready && server.Pending == PendingNone)
+}
+
+func (c *ClientConfig) RemoveUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error {
+	_, err := c.StopServer(ctx, username, serverName, true, opts...)
+	return err
+}
+
+func (c *ClientConfig) ListUserTokens(ctx context.Context, username string, opts ...RequestOption) (*ListTokenResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("users", username, "tokens"), "application/json", nil, operation(opts, "ListUserTokens", "users/{name}/tokens")...)
 	if err != nil {
 		return nil, err
 	}
@@ -343,12 +597,12 @@ func (c *ClientConfig) ListUserTokens(ctx context.Context, username string) (*Li
 	return &result, nil
 }
 
-func (c *ClientConfig) CreateUserToken(ctx context.Context, username string, options *CreateUserTokenBody) (*CreateUserTokenResponse, error) {
+func (c *ClientConfig) CreateUserToken(ctx context.Context, username string, options *CreateUserTokenBody, opts ...RequestOption) (*CreateUserTokenResponse, error) {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return nil, err
 	}
-	data, err := c.Request(ctx, http.MethodPost, fmt.Sprintf("users/%s/tokens", username), "application/json", body)
+	data, err := c.Request(ctx, http.MethodPost, apiPath("users", username, "tokens"), "application/json", body, operation(opts, "CreateUserToken", "users/{name}/tokens")...)
 	if err != nil {
 		return nil, err
 	}
@@ -360,8 +614,8 @@ func (c *ClientConfig) CreateUserToken(ctx context.Context, username string, opt
 	return &result, nil
 }
 
-func (c *ClientConfig) GetUserToken(ctx context.Context, username string, tokenId string) (*GetUserTokenResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("users/%s/tokens/%s", username, tokenId), "application/json", nil)
+func (c *ClientConfig) GetUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) (*GetUserTokenResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("users", username, "tokens", tokenId), "application/json", nil, operation(opts, "GetUserToken", "users/{name}/tokens/{token_id}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -373,21 +627,24 @@ func (c *ClientConfig) GetUserToken(ctx context.Context, username string, tokenI
 	return &result, nil
 }
 
-func (c *ClientConfig) DeleteUserToken(ctx context.Context, username string, tokenId string) error {
-	_, err := c.Request(ctx, http.MethodDelete, fmt.Sprintf("users/%s/tokens/%s", username, tokenId), "application/json", nil)
+func (c *ClientConfig) DeleteUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodDelete, apiPath("users", username, "tokens", tokenId), "application/json", nil, operation(opts, "DeleteUserToken", "users/{name}/tokens/{token_id}")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) ListGroups(ctx context.Context, options *ListGroupsParams) (*ListGroupsResponse, error) {
-	url := "groups"
+func (c *ClientConfig) ListGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsResponse, error) {
+	var query url.Values
 	if options != nil {
-		url = fmt.Sprintf("%s?%s", url, options.Encode())
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
 	}
 
-	data, err := c.Request(ctx, http.MethodGet, url, "application/json", nil)
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "groups"), "application/json", nil, operation(opts, "ListGroups", "groups")...)
 	if err != nil {
 		return nil, err
 	}
@@ -399,8 +656,55 @@ func (c *ClientConfig) ListGroups(ctx context.Context, options *ListGroupsParams
 	return &result, nil
 }
 
-func (c *ClientConfig) GetGroup(ctx context.Context, groupname string) (*GetGroupResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("groups/%s", groupname), "application/json", nil)
+func (c *ClientConfig) ListGroupsPaginated(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsPage, error) {
+	var query url.Values
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
+	}
+
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "groups"), "application/json", nil, paginated(operation(opts, "ListGroupsPaginated", "groups"))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result ListGroupsPage
+	result.Pagination, err = decodePage(data, &result.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ClientConfig) ListAllGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (ListGroupsResponse, error) {
+	params := ListGroupsParams{}
+	if options != nil {
+		params = *options
+	}
+
+	result := ListGroupsResponse{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := c.ListGroupsPaginated(ctx, &params, opts...)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, page.Items...)
+
+		offset, limit, ok := nextPage(page.Pagination, params.Offset, params.Limit, len(page.Items))
+		if !ok {
+			return result, nil
+		}
+		params.Offset, params.Limit = offset, limit
+	}
+}
+
+func (c *ClientConfig) GetGroup(ctx context.Context, groupname string, opts ...RequestOption) (*GetGroupResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("groups", groupname), "application/json", nil, operation(opts, "GetGroup", "groups/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -412,8 +716,8 @@ func (c *ClientConfig) GetGroup(ctx context.Context, groupname string) (*GetGrou
 	return &result, nil
 }
 
-func (c *ClientConfig) CreateGroup(ctx context.Context, groupname string) (*CreateGroupResponse, error) {
-	data, err := c.Request(ctx, http.MethodPost, fmt.Sprintf("groups/%s", groupname), "application/json", nil)
+func (c *ClientConfig) CreateGroup(ctx context.Context, groupname string, opts ...RequestOption) (*CreateGroupResponse, error) {
+	data, err := c.Request(ctx, http.MethodPost, apiPath("groups", groupname), "application/json", nil, operation(opts, "CreateGroup", "groups/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -425,20 +729,20 @@ func (c *ClientConfig) CreateGroup(ctx context.Context, groupname string) (*Crea
 	return &result, nil
 }
 
-func (c *ClientConfig) DeleteGroup(ctx context.Context, groupname string) error {
-	_, err := c.Request(ctx, http.MethodDelete, fmt.Sprintf("groups/%s", groupname), "application/json", nil)
+func (c *ClientConfig) DeleteGroup(ctx context.Context, groupname string, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodDelete, apiPath("groups", groupname), "application/json", nil, operation(opts, "DeleteGroup", "groups/{name}")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) AddGroupUsers(ctx context.Context, groupname string, options *AddGroupUsersBody) (*AddGroupUsersResponse, error) {
+func (c *ClientConfig) AddGroupUsers(ctx context.Context, groupname string, options *AddGroupUsersBody, opts ...RequestOption) (*AddGroupUsersResponse, error) {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return nil, err
 	}
-	data, err := c.Request(ctx, http.MethodPost, fmt.Sprintf("groups/%s/users", groupname), "application/json", body)
+	data, err := c.Request(ctx, http.MethodPost, apiPath("groups", groupname, "users"), "application/json", body, operation(opts, "AddGroupUsers", "groups/{name}/users")...)
 	if err != nil {
 		return nil, err
 	}
@@ -450,32 +754,32 @@ func (c *ClientConfig) AddGroupUsers(ctx context.Context, groupname string, opti
 	return &result, nil
 }
 
-func (c *ClientConfig) RemoveGroupUsers(ctx context.Context, groupname string, options *RemoveGroupUsersBody) error {
+func (c *ClientConfig) RemoveGroupUsers(ctx context.Context, groupname string, options *RemoveGroupUsersBody, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
-	_, err = c.Request(ctx, http.MethodDelete, fmt.Sprintf("groups/%s/users", groupname), "application/json", body)
+	_, err = c.Request(ctx, http.MethodDelete, apiPath("groups", groupname, "users"), "application/json", body, operation(opts, "RemoveGroupUsers", "groups/{name}/users")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) SetGroupProperties(ctx context.Context, groupname string, properties interface{}) error {
+func (c *ClientConfig) SetGroupProperties(ctx context.Context, groupname string, properties interface{}, opts ...RequestOption) error {
 	body, err := json.Marshal(properties)
 	if err != nil {
 		return err
 	}
-	_, err = c.Request(ctx, http.MethodPut, fmt.Sprintf("groups/%s/properties", groupname), "application/json", body)
+	_, err = c.Request(ctx, http.MethodPut, apiPath("groups", groupname, "properties"), "application/json", body, operation(opts, "SetGroupProperties", "groups/{name}/properties")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) ListServices(ctx context.Context) (*ListServicesResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, "services", "application/json", nil)
+func (c *ClientConfig) ListServices(ctx context.Context, opts ...RequestOption) (*ListServicesResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, "services", "application/json", nil, operation(opts, "ListServices", "services")...)
 	if err != nil {
 		return nil, err
 	}
@@ -487,8 +791,8 @@ func (c *ClientConfig) ListServices(ctx context.Context) (*ListServicesResponse,
 	return &result, nil
 }
 
-func (c *ClientConfig) GetService(ctx context.Context, servicename string) (*GetServiceResponse, error) {
-	data, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("services/%s", servicename), "application/json", nil)
+func (c *ClientConfig) GetService(ctx context.Context, servicename string, opts ...RequestOption) (*GetServiceResponse, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("services", servicename), "application/json", nil, operation(opts, "GetService", "services/{name}")...)
 	if err != nil {
 		return nil, err
 	}
@@ -500,13 +804,16 @@ func (c *ClientConfig) GetService(ctx context.Context, servicename string) (*Get
 	return &result, nil
 }
 
-func (c *ClientConfig) GetProxyTable(ctx context.Context, options *GetProxyTableParams) (*GetProxyTableResponse, error) {
-	url := "proxy"
+func (c *ClientConfig) GetProxyTable(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTableResponse, error) {
+	var query url.Values
 	if options != nil {
-		url = fmt.Sprintf("%s?%s", url, options.Encode())
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
 	}
 
-	data, err := c.Request(ctx, http.MethodGet, url, "application/json", nil)
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "proxy"), "application/json", nil, operation(opts, "GetProxyTable", "proxy")...)
 	if err != nil {
 		return nil, err
 	}
@@ -518,32 +825,81 @@ func (c *ClientConfig) GetProxyTable(ctx context.Context, options *GetProxyTable
 	return &result, nil
 }
 
-func (c *ClientConfig) ForceProxySync(ctx context.Context) error {
-	_, err := c.Request(ctx, http.MethodPost, "proxy", "application/json", nil)
+func (c *ClientConfig) GetProxyTablePaginated(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTablePage, error) {
+	var query url.Values
+	if options != nil {
+		if err := options.Validate(); err != nil {
+			return nil, err
+		}
+		query = options.values()
+	}
+
+	data, err := c.Request(ctx, http.MethodGet, apiPathWithQuery(query, "proxy"), "application/json", nil, paginated(operation(opts, "GetProxyTablePaginated", "proxy"))...)
+	if err != nil {
+		return nil, err
+	}
+
+	var result GetProxyTablePage
+	result.Pagination, err = decodePage(data, &result.Items)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func (c *ClientConfig) AllProxyRoutes(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (GetProxyTableResponse, error) {
+	params := GetProxyTableParams{}
+	if options != nil {
+		params = *options
+	}
+
+	result := GetProxyTableResponse{}
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		page, err := c.GetProxyTablePaginated(ctx, &params, opts...)
+		if err != nil {
+			return nil, err
+		}
+		for key, route := range page.Items {
+			result[key] = route
+		}
+
+		offset, limit, ok := nextPage(page.Pagination, params.Offset, params.Limit, len(page.Items))
+		if !ok {
+			return result, nil
+		}
+		params.Offset, params.Limit = offset, limit
+	}
+}
+
+func (c *ClientConfig) ForceProxySync(ctx context.Context, opts ...RequestOption) error {
+	_, err := c.Request(ctx, http.MethodPost, "proxy", "application/json", nil, operation(opts, "ForceProxySync", "proxy")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) NotifyNewProxy(ctx context.Context, options *NotifyNewProxyBody) error {
+func (c *ClientConfig) NotifyNewProxy(ctx context.Context, options *NotifyNewProxyBody, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
-	_, err = c.Request(ctx, http.MethodPost, "proxy", "application/json", body)
+	_, err = c.Request(ctx, http.MethodPost, "proxy", "application/json", body, operation(opts, "NotifyNewProxy", "proxy")...)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (c *ClientConfig) NewAPIToken(ctx context.Context, options *NewTokenBody) (*NewTokenResponse, error) {
+func (c *ClientConfig) NewAPIToken(ctx context.Context, options *NewTokenBody, opts ...RequestOption) (*NewTokenResponse, error) {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return nil, err
 	}
-	data, err := c.Request(ctx, http.MethodPost, "authorizations/token", "application/json", body)
+	data, err := c.Request(ctx, http.MethodPost, "authorizations/token", "application/json", body, operation(opts, "NewAPIToken", "authorizations/token")...)
 	if err != nil {
 		return nil, err
 	}
@@ -554,12 +910,36 @@ func (c *ClientConfig) NewAPIToken(ctx context.Context, options *NewTokenBody) (
 	return &result, nil
 }
 
-func (c *ClientConfig) ValidateToken(ctx context.Context, token string) error {
-	_, err := c.Request(ctx, http.MethodGet, fmt.Sprintf("authorizations/token/%s", token), "application/json", nil)
+// ValidateToken resolves token through the deprecated
+// authorizations/token endpoint. Prefer AuthenticateToken on JupyterHub 2+.
+func (c *ClientConfig) ValidateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error) {
+	data, err := c.Request(ctx, http.MethodGet, apiPath("authorizations", "token", token), "application/json", nil, operation(opts, "ValidateToken", "authorizations/token/{token}")...)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	return decodeIdentity(data)
+}
+
+// AuthenticateToken resolves the user or service that owns token by calling
+// the hub's user endpoint with token as the bearer, which is how services
+// authenticate requests made to them.
+func (c *ClientConfig) AuthenticateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error) {
+	data, err := c.Request(ctx, http.MethodGet, "user", "application/json", nil, append(operation(opts, "AuthenticateToken", "user"), WithToken(token))...)
+	if err != nil {
+		return nil, err
+	}
+	return decodeIdentity(data)
+}
+
+func decodeIdentity(data []byte) (*HubIdentity, error) {
+	var result HubIdentity
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	if result.Kind == "" {
+		result.Kind = IdentityKindUser
+	}
+	return &result, nil
 }
 
 func (c *ClientConfig) GetOAuth2Endpoint(options *GetOAuth2EndpointParams) (string, error) {
@@ -583,18 +963,43 @@ func (c *ClientConfig) GetOAuth2Endpoint(options *GetOAuth2EndpointParams) (stri
 		return "", errors.New("RedirectUri not set for OAuth request and is required")
 	}
 
-	return fmt.Sprintf("%s/oauth2/authorize?%s", c.ApiURL, options.Encode()), nil
+	return fmt.Sprintf("%s?%s", c.authorizeURL(), options.Encode()), nil
+}
+
+// authorizeURL is the hub's OAuth authorize endpoint as seen by browsers.
+// Services run behind the hub's proxy where ApiURL is an internal address,
+// so the path under BaseURL is used when the hub has provided it. Host is
+// empty when the hub is served from the same domain as the service, and the
+// URL is then relative to it.
+func (c *ClientConfig) authorizeURL() string {
+	if c.BaseURL != "" {
+		return fmt.Sprintf("%s%s/hub/api/oauth2/authorize", c.Host, strings.TrimSuffix(c.BaseURL, "/"))
+	}
+	return fmt.Sprintf("%s/oauth2/authorize", c.ApiURL)
 }
 
+// ParseOAuthRequest returns the authorization code from the request to the
+// redirect_uri. Errors sent back by the hub, such as access_denied, are
+// returned as an *OAuthError.
 func (c *ClientConfig) ParseOAuthRequest(r *http.Request, state string) (string, error) {
 	query := r.URL.Query()
 	if state != query.Get("state") {
-		return "", errors.New("state of request did not match expected state")
+		return "", ErrOAuthStateMismatch
+	}
+	if query.Get("error") != "" {
+		return "", &OAuthError{
+			Code:        query.Get("error"),
+			Description: query.Get("error_description"),
+			URI:         query.Get("error_uri"),
+		}
+	}
+	if query.Get("code") == "" {
+		return "", &OAuthError{Code: "invalid_request", Description: "code missing from OAuth redirect"}
 	}
 	return query.Get("code"), nil
 }
 
-func (c *ClientConfig) GetOAuth2Token(ctx context.Context, options *GetOAuth2TokenBody) (*GetOAuth2TokenResponse, error) {
+func (c *ClientConfig) GetOAuth2Token(ctx context.Context, options *GetOAuth2TokenBody, opts ...RequestOption) (*GetOAuth2TokenResponse, error) {
 	if options.ClientId == "" && c.ClientId != "" {
 		options.ClientId = c.ClientId
 	} else if options.ClientId == "" && c.ServiceName != "" {
@@ -611,9 +1016,9 @@ func (c *ClientConfig) GetOAuth2Token(ctx context.Context, options *GetOAuth2Tok
 		options.GrantType = "authorization_code"
 	}
 
-	data, err := c.Request(ctx, http.MethodPost, "oauth2/token", "application/x-www-form-urlencoded", []byte(options.Encode()))
+	data, err := c.Request(ctx, http.MethodPost, "oauth2/token", "application/x-www-form-urlencoded", []byte(options.Encode()), operation(opts, "GetOAuth2Token", "oauth2/token")...)
 	if err != nil {
-		return nil, err
+		return nil, asOAuthError(err)
 	}
 	var result GetOAuth2TokenResponse
 	if err := json.Unmarshal(data, &result); err != nil {
@@ -622,12 +1027,16 @@ func (c *ClientConfig) GetOAuth2Token(ctx context.Context, options *GetOAuth2Tok
 	return &result, nil
 }
 
-func (c *ClientConfig) Shutdown(ctx context.Context, options *ShutdownBody) error {
+func (c *ClientConfig) RefreshOAuth2Token(ctx context.Context, refreshToken string, opts ...RequestOption) (*GetOAuth2TokenResponse, error) {
+	return c.GetOAuth2Token(ctx, &GetOAuth2TokenBody{GrantType: "refresh_token", RefreshToken: refreshToken}, opts...)
+}
+
+func (c *ClientConfig) Shutdown(ctx context.Context, options *ShutdownBody, opts ...RequestOption) error {
 	body, err := json.Marshal(options)
 	if err != nil {
 		return err
 	}
-	_, err = c.Request(ctx, http.MethodPost, "shutdown", "application/json", body)
+	_, err = c.Request(ctx, http.MethodPost, "shutdown", "application/json", body, operation(opts, "Shutdown", "shutdown")...)
 	if err != nil {
 		return err
 	}
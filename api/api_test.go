@@ -2,15 +2,53 @@ package api
 
 import (
 	"context"
+	"flag"
+	"net/http"
+	"path/filepath"
 	"regexp"
 	"testing"
+
+	"github.com/costrouc/go-jupyterhub-api/replay"
 )
 
-func TestJupyterHubStatus(t *testing.T) {
-	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken"})
+var record = flag.Bool("record", false, "run the TestJupyterHub tests against the hub started by compose.yaml and record their fixtures in testdata")
+
+// jupyterhubClient returns a client for the TestJupyterHub tests that
+// replays testdata/<test name>.json, or records it with -record.
+func jupyterhubClient(t *testing.T, token string) *ClientConfig {
+	t.Helper()
+	path := filepath.Join("testdata", t.Name()+".json")
+	options := &replay.Options{
+		Secrets:  []string{token},
+		Volatile: []string{"created", "last_activity", "started", "python"},
+	}
+
+	var transport http.RoundTripper
+	if *record {
+		recorder := replay.NewRecorder(nil, options)
+		t.Cleanup(func() {
+			if err := recorder.Save(path); err != nil {
+				t.Error(err)
+			}
+		})
+		transport = recorder
+	} else {
+		player, err := replay.Load(path, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transport = player
+	}
+
+	client, err := CreateClient(&ClientConfig{ApiToken: token, Transport: transport})
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
+	return client
+}
+
+func TestJupyterHubStatus(t *testing.T) {
+	client := jupyterhubClient(t, "usertoken")
 	ctx := context.Background()
 	data, err := client.GetInfo(ctx)
 	if err != nil {
@@ -23,10 +61,7 @@ func TestJupyterHubStatus(t *testing.T) {
 }
 
 func TestJupyterHubGetVersion(t *testing.T) {
-	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken"})
-	if err != nil {
-		t.Error(err)
-	}
+	client := jupyterhubClient(t, "usertoken")
 	ctx := context.Background()
 	data, err := client.GetVersion(ctx)
 	if err != nil {
@@ -38,10 +73,7 @@ func TestJupyterHubGetVersion(t *testing.T) {
 }
 
 func TestJupyterHubGetCurrentUser(t *testing.T) {
-	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken"})
-	if err != nil {
-		t.Error(err)
-	}
+	client := jupyterhubClient(t, "usertoken")
 	ctx := context.Background()
 	data, err := client.GetCurrentUser(ctx)
 	if err != nil {
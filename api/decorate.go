@@ -0,0 +1,446 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// Call describes a Hub method invocation seen by an Interceptor. Write is set
+// for methods that modify the hub.
+type Call struct {
+	Method string
+	Write  bool
+}
+
+// Interceptor wraps a single Hub method call. It must call invoke, possibly
+// with a derived context, to perform the call, or return an error instead.
+type Interceptor func(ctx context.Context, call Call, invoke func(ctx context.Context) error) error
+
+// Decorate returns a Hub that passes every call to hub through interceptors.
+// The first interceptor is the outermost. Composite methods such as
+// StartAndWait and ModifyUser are intercepted as a single call.
+func Decorate(hub Hub, interceptors ...Interceptor) Hub {
+	return &decoratedHub{hub: hub, interceptors: interceptors}
+}
+
+// LogCalls logs every call with its duration and error to logger, or to the
+// default logger when nil. Failed calls are logged at the error level, with
+// tokens redacted from the error.
+func LogCalls(logger *slog.Logger) Interceptor {
+	return func(ctx context.Context, call Call, invoke func(ctx context.Context) error) error {
+		l := logger
+		if l == nil {
+			l = slog.Default()
+		}
+		start := time.Now()
+		err := invoke(ctx)
+		attrs := []slog.Attr{
+			slog.String("method", call.Method),
+			slog.Bool("write", call.Write),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if err != nil {
+			l.LogAttrs(ctx, slog.LevelError, "jupyterhub call failed", append(attrs, slog.String("error", redact.Path(err.Error())))...)
+		} else {
+			l.LogAttrs(ctx, slog.LevelInfo, "jupyterhub call", attrs...)
+		}
+		return err
+	}
+}
+
+// ObserveCalls calls observe after every call with its duration and error.
+func ObserveCalls(observe func(call Call, duration time.Duration, err error)) Interceptor {
+	return func(ctx context.Context, call Call, invoke func(ctx context.Context) error) error {
+		start := time.Now()
+		err := invoke(ctx)
+		observe(call, time.Since(start), err)
+		return err
+	}
+}
+
+// ReadOnly rejects every call that would modify the hub with an error
+// wrapping ErrReadOnly, without sending it.
+func ReadOnly() Interceptor {
+	return func(ctx context.Context, call Call, invoke func(ctx context.Context) error) error {
+		if call.Write {
+			return fmt.Errorf("%s: %w", call.Method, ErrReadOnly)
+		}
+		return invoke(ctx)
+	}
+}
+
+// CallStats are the totals CallCounter keeps for one method.
+type CallStats struct {
+	Calls    int
+	Errors   int
+	Duration time.Duration
+}
+
+// CallCounter counts calls, errors and total duration per method. The zero
+// value is ready to use.
+type CallCounter struct {
+	mu    sync.Mutex
+	stats map[string]CallStats
+}
+
+// Interceptor returns an interceptor that records calls in the counter.
+func (c *CallCounter) Interceptor() Interceptor {
+	return ObserveCalls(func(call Call, duration time.Duration, err error) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		if c.stats == nil {
+			c.stats = map[string]CallStats{}
+		}
+		stats := c.stats[call.Method]
+		stats.Calls++
+		if err != nil {
+			stats.Errors++
+		}
+		stats.Duration += duration
+		c.stats[call.Method] = stats
+	})
+}
+
+// Stats returns a copy of the totals keyed by method name.
+func (c *CallCounter) Stats() map[string]CallStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]CallStats, len(c.stats))
+	for method, s := range c.stats {
+		stats[method] = s
+	}
+	return stats
+}
+
+type decoratedHub struct {
+	hub          Hub
+	interceptors []Interceptor
+}
+
+func (d *decoratedHub) invoke(ctx context.Context, call Call, fn func(ctx context.Context) error) error {
+	next := fn
+	for i := len(d.interceptors) - 1; i >= 0; i-- {
+		interceptor, inner := d.interceptors[i], next
+		next = func(ctx context.Context) error {
+			return interceptor(ctx, call, inner)
+		}
+	}
+	return next(ctx)
+}
+
+func intercept[T any](d *decoratedHub, ctx context.Context, call Call, fn func(ctx context.Context) (T, error)) (T, error) {
+	var result T
+	err := d.invoke(ctx, call, func(ctx context.Context) error {
+		var err error
+		result, err = fn(ctx)
+		return err
+	})
+	return result, err
+}
+
+func readCall(method string) Call {
+	return Call{Method: method}
+}
+
+func writeCall(method string) Call {
+	return Call{Method: method, Write: true}
+}
+
+func (d *decoratedHub) GetInfo(ctx context.Context, opts ...RequestOption) (*InfoResponse, error) {
+	return intercept(d, ctx, readCall("GetInfo"), func(ctx context.Context) (*InfoResponse, error) {
+		return d.hub.GetInfo(ctx, opts...)
+	})
+}
+
+func (d *decoratedHub) GetVersion(ctx context.Context, opts ...RequestOption) (*VersionResponse, error) {
+	return intercept(d, ctx, readCall("GetVersion"), func(ctx context.Context) (*VersionResponse, error) {
+		return d.hub.GetVersion(ctx, opts...)
+	})
+}
+
+func (d *decoratedHub) ListServices(ctx context.Context, opts ...RequestOption) (*ListServicesResponse, error) {
+	return intercept(d, ctx, readCall("ListServices"), func(ctx context.Context) (*ListServicesResponse, error) {
+		return d.hub.ListServices(ctx, opts...)
+	})
+}
+
+func (d *decoratedHub) GetService(ctx context.Context, servicename string, opts ...RequestOption) (*GetServiceResponse, error) {
+	return intercept(d, ctx, readCall("GetService"), func(ctx context.Context) (*GetServiceResponse, error) {
+		return d.hub.GetService(ctx, servicename, opts...)
+	})
+}
+
+func (d *decoratedHub) Shutdown(ctx context.Context, options *ShutdownBody, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("Shutdown"), func(ctx context.Context) error {
+		return d.hub.Shutdown(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) GetCurrentUser(ctx context.Context, opts ...RequestOption) (*CurrentUserResponse, error) {
+	return intercept(d, ctx, readCall("GetCurrentUser"), func(ctx context.Context) (*CurrentUserResponse, error) {
+		return d.hub.GetCurrentUser(ctx, opts...)
+	})
+}
+
+func (d *decoratedHub) ListUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersResponse, error) {
+	return intercept(d, ctx, readCall("ListUsers"), func(ctx context.Context) (*ListUsersResponse, error) {
+		return d.hub.ListUsers(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ListUsersPaginated(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersPage, error) {
+	return intercept(d, ctx, readCall("ListUsersPaginated"), func(ctx context.Context) (*ListUsersPage, error) {
+		return d.hub.ListUsersPaginated(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ListAllUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (ListUsersResponse, error) {
+	return intercept(d, ctx, readCall("ListAllUsers"), func(ctx context.Context) (ListUsersResponse, error) {
+		return d.hub.ListAllUsers(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) CreateUsers(ctx context.Context, options *CreateUsersBody, opts ...RequestOption) (*ListUsersResponse, error) {
+	return intercept(d, ctx, writeCall("CreateUsers"), func(ctx context.Context) (*ListUsersResponse, error) {
+		return d.hub.CreateUsers(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) GetUser(ctx context.Context, username string, opts ...RequestOption) (*GetUserResponse, error) {
+	return intercept(d, ctx, readCall("GetUser"), func(ctx context.Context) (*GetUserResponse, error) {
+		return d.hub.GetUser(ctx, username, opts...)
+	})
+}
+
+func (d *decoratedHub) CreateUser(ctx context.Context, username string, opts ...RequestOption) (*CreateUserResponse, error) {
+	return intercept(d, ctx, writeCall("CreateUser"), func(ctx context.Context) (*CreateUserResponse, error) {
+		return d.hub.CreateUser(ctx, username, opts...)
+	})
+}
+
+func (d *decoratedHub) DeleteUser(ctx context.Context, username string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("DeleteUser"), func(ctx context.Context) error {
+		return d.hub.DeleteUser(ctx, username, opts...)
+	})
+}
+
+func (d *decoratedHub) UpdateUser(ctx context.Context, username string, options *UpdateUserBody, opts ...RequestOption) (*UpdateUserResponse, error) {
+	return intercept(d, ctx, writeCall("UpdateUser"), func(ctx context.Context) (*UpdateUserResponse, error) {
+		return d.hub.UpdateUser(ctx, username, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ModifyUser(ctx context.Context, username string, mutate func(user *JupyterHubUser) error, opts ...RequestOption) (*UpdateUserResponse, error) {
+	return intercept(d, ctx, writeCall("ModifyUser"), func(ctx context.Context) (*UpdateUserResponse, error) {
+		return d.hub.ModifyUser(ctx, username, mutate, opts...)
+	})
+}
+
+func (d *decoratedHub) NotifyUserActivity(ctx context.Context, username string, options *UserActivityBody, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("NotifyUserActivity"), func(ctx context.Context) error {
+		return d.hub.NotifyUserActivity(ctx, username, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ListGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsResponse, error) {
+	return intercept(d, ctx, readCall("ListGroups"), func(ctx context.Context) (*ListGroupsResponse, error) {
+		return d.hub.ListGroups(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ListGroupsPaginated(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsPage, error) {
+	return intercept(d, ctx, readCall("ListGroupsPaginated"), func(ctx context.Context) (*ListGroupsPage, error) {
+		return d.hub.ListGroupsPaginated(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ListAllGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (ListGroupsResponse, error) {
+	return intercept(d, ctx, readCall("ListAllGroups"), func(ctx context.Context) (ListGroupsResponse, error) {
+		return d.hub.ListAllGroups(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) GetGroup(ctx context.Context, groupname string, opts ...RequestOption) (*GetGroupResponse, error) {
+	return intercept(d, ctx, readCall("GetGroup"), func(ctx context.Context) (*GetGroupResponse, error) {
+		return d.hub.GetGroup(ctx, groupname, opts...)
+	})
+}
+
+func (d *decoratedHub) CreateGroup(ctx context.Context, groupname string, opts ...RequestOption) (*CreateGroupResponse, error) {
+	return intercept(d, ctx, writeCall("CreateGroup"), func(ctx context.Context) (*CreateGroupResponse, error) {
+		return d.hub.CreateGroup(ctx, groupname, opts...)
+	})
+}
+
+func (d *decoratedHub) DeleteGroup(ctx context.Context, groupname string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("DeleteGroup"), func(ctx context.Context) error {
+		return d.hub.DeleteGroup(ctx, groupname, opts...)
+	})
+}
+
+func (d *decoratedHub) AddGroupUsers(ctx context.Context, groupname string, options *AddGroupUsersBody, opts ...RequestOption) (*AddGroupUsersResponse, error) {
+	return intercept(d, ctx, writeCall("AddGroupUsers"), func(ctx context.Context) (*AddGroupUsersResponse, error) {
+		return d.hub.AddGroupUsers(ctx, groupname, options, opts...)
+	})
+}
+
+func (d *decoratedHub) RemoveGroupUsers(ctx context.Context, groupname string, options *RemoveGroupUsersBody, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("RemoveGroupUsers"), func(ctx context.Context) error {
+		return d.hub.RemoveGroupUsers(ctx, groupname, options, opts...)
+	})
+}
+
+func (d *decoratedHub) SetGroupProperties(ctx context.Context, groupname string, properties interface{}, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("SetGroupProperties"), func(ctx context.Context) error {
+		return d.hub.SetGroupProperties(ctx, groupname, properties, opts...)
+	})
+}
+
+func (d *decoratedHub) PatchGroupProperties(ctx context.Context, groupname string, patch interface{}, opts ...RequestOption) (map[string]interface{}, error) {
+	return intercept(d, ctx, writeCall("PatchGroupProperties"), func(ctx context.Context) (map[string]interface{}, error) {
+		return d.hub.PatchGroupProperties(ctx, groupname, patch, opts...)
+	})
+}
+
+func (d *decoratedHub) ListUserTokens(ctx context.Context, username string, opts ...RequestOption) (*ListTokenResponse, error) {
+	return intercept(d, ctx, readCall("ListUserTokens"), func(ctx context.Context) (*ListTokenResponse, error) {
+		return d.hub.ListUserTokens(ctx, username, opts...)
+	})
+}
+
+func (d *decoratedHub) CreateUserToken(ctx context.Context, username string, options *CreateUserTokenBody, opts ...RequestOption) (*CreateUserTokenResponse, error) {
+	return intercept(d, ctx, writeCall("CreateUserToken"), func(ctx context.Context) (*CreateUserTokenResponse, error) {
+		return d.hub.CreateUserToken(ctx, username, options, opts...)
+	})
+}
+
+func (d *decoratedHub) GetUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) (*GetUserTokenResponse, error) {
+	return intercept(d, ctx, readCall("GetUserToken"), func(ctx context.Context) (*GetUserTokenResponse, error) {
+		return d.hub.GetUserToken(ctx, username, tokenId, opts...)
+	})
+}
+
+func (d *decoratedHub) DeleteUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("DeleteUserToken"), func(ctx context.Context) error {
+		return d.hub.DeleteUserToken(ctx, username, tokenId, opts...)
+	})
+}
+
+func (d *decoratedHub) NewAPIToken(ctx context.Context, options *NewTokenBody, opts ...RequestOption) (*NewTokenResponse, error) {
+	return intercept(d, ctx, writeCall("NewAPIToken"), func(ctx context.Context) (*NewTokenResponse, error) {
+		return d.hub.NewAPIToken(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ValidateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error) {
+	return intercept(d, ctx, readCall("ValidateToken"), func(ctx context.Context) (*HubIdentity, error) {
+		return d.hub.ValidateToken(ctx, token, opts...)
+	})
+}
+
+func (d *decoratedHub) AuthenticateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error) {
+	return intercept(d, ctx, readCall("AuthenticateToken"), func(ctx context.Context) (*HubIdentity, error) {
+		return d.hub.AuthenticateToken(ctx, token, opts...)
+	})
+}
+
+func (d *decoratedHub) StartUserServer(ctx context.Context, username string, options interface{}, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("StartUserServer"), func(ctx context.Context) error {
+		return d.hub.StartUserServer(ctx, username, options, opts...)
+	})
+}
+
+func (d *decoratedHub) StopUserServer(ctx context.Context, username string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("StopUserServer"), func(ctx context.Context) error {
+		return d.hub.StopUserServer(ctx, username, opts...)
+	})
+}
+
+func (d *decoratedHub) StartUserNamedServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("StartUserNamedServer"), func(ctx context.Context) error {
+		return d.hub.StartUserNamedServer(ctx, username, serverName, options, opts...)
+	})
+}
+
+func (d *decoratedHub) StopUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("StopUserNamedServer"), func(ctx context.Context) error {
+		return d.hub.StopUserNamedServer(ctx, username, serverName, opts...)
+	})
+}
+
+func (d *decoratedHub) RemoveUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("RemoveUserNamedServer"), func(ctx context.Context) error {
+		return d.hub.RemoveUserNamedServer(ctx, username, serverName, opts...)
+	})
+}
+
+func (d *decoratedHub) StartServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) (StartResult, error) {
+	return intercept(d, ctx, writeCall("StartServer"), func(ctx context.Context) (StartResult, error) {
+		return d.hub.StartServer(ctx, username, serverName, options, opts...)
+	})
+}
+
+func (d *decoratedHub) StopServer(ctx context.Context, username string, serverName string, remove bool, opts ...RequestOption) (StopResult, error) {
+	return intercept(d, ctx, writeCall("StopServer"), func(ctx context.Context) (StopResult, error) {
+		return d.hub.StopServer(ctx, username, serverName, remove, opts...)
+	})
+}
+
+func (d *decoratedHub) StartAndWait(ctx context.Context, username string, serverName string, options interface{}, wait *WaitOptions, opts ...RequestOption) (*JupyterHubServer, error) {
+	return intercept(d, ctx, writeCall("StartAndWait"), func(ctx context.Context) (*JupyterHubServer, error) {
+		return d.hub.StartAndWait(ctx, username, serverName, options, wait, opts...)
+	})
+}
+
+func (d *decoratedHub) StopAndWait(ctx context.Context, username string, serverName string, wait *WaitOptions, opts ...RequestOption) (StopResult, error) {
+	return intercept(d, ctx, writeCall("StopAndWait"), func(ctx context.Context) (StopResult, error) {
+		return d.hub.StopAndWait(ctx, username, serverName, wait, opts...)
+	})
+}
+
+func (d *decoratedHub) WatchServerProgress(ctx context.Context, username string, serverName string, fn func(event ProgressEvent) error, opts ...RequestOption) error {
+	return d.invoke(ctx, readCall("WatchServerProgress"), func(ctx context.Context) error {
+		return d.hub.WatchServerProgress(ctx, username, serverName, fn, opts...)
+	})
+}
+
+func (d *decoratedHub) ServerProgressEvents(ctx context.Context, username string, serverName string, opts ...RequestOption) (<-chan ProgressEvent, <-chan error) {
+	return progressEvents(ctx, func(fn func(event ProgressEvent) error) error {
+		return d.WatchServerProgress(ctx, username, serverName, fn, opts...)
+	})
+}
+
+func (d *decoratedHub) GetProxyTable(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTableResponse, error) {
+	return intercept(d, ctx, readCall("GetProxyTable"), func(ctx context.Context) (*GetProxyTableResponse, error) {
+		return d.hub.GetProxyTable(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) GetProxyTablePaginated(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTablePage, error) {
+	return intercept(d, ctx, readCall("GetProxyTablePaginated"), func(ctx context.Context) (*GetProxyTablePage, error) {
+		return d.hub.GetProxyTablePaginated(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) AllProxyRoutes(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (GetProxyTableResponse, error) {
+	return intercept(d, ctx, readCall("AllProxyRoutes"), func(ctx context.Context) (GetProxyTableResponse, error) {
+		return d.hub.AllProxyRoutes(ctx, options, opts...)
+	})
+}
+
+func (d *decoratedHub) ForceProxySync(ctx context.Context, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("ForceProxySync"), func(ctx context.Context) error {
+		return d.hub.ForceProxySync(ctx, opts...)
+	})
+}
+
+func (d *decoratedHub) NotifyNewProxy(ctx context.Context, options *NotifyNewProxyBody, opts ...RequestOption) error {
+	return d.invoke(ctx, writeCall("NotifyNewProxy"), func(ctx context.Context) error {
+		return d.hub.NotifyNewProxy(ctx, options, opts...)
+	})
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDecorate(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/users/alice":
+			w.Write([]byte(`{"name": "alice"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var order []string
+	trace := func(name string) Interceptor {
+		return func(ctx context.Context, call Call, invoke func(ctx context.Context) error) error {
+			order = append(order, name+" "+call.Method)
+			return invoke(ctx)
+		}
+	}
+	var logs bytes.Buffer
+	counter := &CallCounter{}
+	hub := Decorate(client, trace("outer"), trace("inner"), LogCalls(slog.New(slog.NewTextHandler(&logs, nil))), counter.Interceptor(), ReadOnly())
+	ctx := context.Background()
+
+	user, err := hub.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Name != "alice" {
+		t.Errorf("Expected alice, got %s", user.Name)
+	}
+	if expected := []string{"outer GetUser", "inner GetUser"}; !reflect.DeepEqual(order, expected) {
+		t.Errorf("Expected interceptors to run in order %v, got %v", expected, order)
+	}
+
+	if _, err := hub.GetGroup(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	if _, err := hub.ValidateToken(ctx, "supersecrettoken123"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	if err := hub.DeleteUser(ctx, "alice"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if _, err := hub.StartServer(ctx, "alice", "", nil); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+	if requests != 3 {
+		t.Errorf("Expected read-only calls not to be sent, got %d requests", requests)
+	}
+
+	stats := counter.Stats()
+	if stats["GetUser"].Calls != 1 || stats["GetUser"].Errors != 0 {
+		t.Errorf("Unexpected GetUser stats %+v", stats["GetUser"])
+	}
+	if stats["GetGroup"].Errors != 1 || stats["DeleteUser"].Errors != 1 {
+		t.Errorf("Expected failed calls to be counted, got %+v", stats)
+	}
+
+	for _, expected := range []string{"method=GetUser", "method=GetGroup", "level=ERROR", "write=true", "method=ValidateToken"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected log to contain %q, got %s", expected, logs.String())
+		}
+	}
+	if strings.Contains(logs.String(), "supersecrettoken123") {
+		t.Errorf("Expected the token to be redacted from the log, got %s", logs.String())
+	}
+}
@@ -0,0 +1,126 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+var (
+	ErrBadRequest      = errors.New("bad request")
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrForbidden       = errors.New("forbidden")
+	ErrNotFound        = errors.New("not found")
+	ErrConflict        = errors.New("conflict")
+	ErrTooManyRequests = errors.New("too many requests")
+	ErrServerError     = errors.New("server error")
+
+	ErrSpawnFailed          = errors.New("spawn failed")
+	ErrServerNotRunning     = errors.New("server is not running")
+	ErrAuthStateUnavailable = errors.New("auth_state not returned by hub, requires enable_auth_state and the admin:auth_state scope")
+	StopWatching            = errors.New("stop watching")
+	ErrOAuthStateMismatch   = errors.New("state of request did not match expected state")
+	ErrReadOnly             = errors.New("call modifies the hub but the client is read-only")
+)
+
+// APIError is returned for every response from JupyterHub with a status
+// code outside of the 2XX range. It matches the sentinel errors above with
+// errors.Is so callers can branch on the kind of failure. Tokens in Path are
+// redacted.
+type APIError struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Message    string
+	Header     http.Header
+	Body       []byte
+	Attempts   int
+}
+
+// jupyterhubErrorBody is the JSON error model written by JupyterHub's
+// APIHandler.write_error.
+type jupyterhubErrorBody struct {
+	Status  int    `json:"status"`
+	Message string `json:"message"`
+}
+
+func newAPIError(method string, path string, resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Method:     method,
+		Path:       redact.Path(path),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+		Body:       body,
+	}
+
+	var errorBody jupyterhubErrorBody
+	if err := json.Unmarshal(body, &errorBody); err == nil {
+		apiErr.Message = errorBody.Message
+	}
+	return apiErr
+}
+
+func (e *APIError) Error() string {
+	if e.Message != "" {
+		return fmt.Sprintf("%s %s: response returned status code of %d instead of 2XX: %s", e.Method, e.Path, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("%s %s: response returned status code of %d instead of 2XX", e.Method, e.Path, e.StatusCode)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrBadRequest:
+		return e.StatusCode == http.StatusBadRequest
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized
+	case ErrForbidden:
+		return e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrConflict:
+		return e.StatusCode == http.StatusConflict
+	case ErrTooManyRequests:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrServerError:
+		return e.StatusCode >= 500
+	}
+	return false
+}
+
+// OAuthError is an OAuth 2.0 error response (RFC 6749 section 4.1.2.1 and
+// 5.2), either passed back to the redirect_uri or returned by the token
+// endpoint. Err holds the APIError for token endpoint failures.
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+	URI         string `json:"error_uri"`
+	Err         error  `json:"-"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth error %s: %s", e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth error %s", e.Code)
+}
+
+func (e *OAuthError) Unwrap() error {
+	return e.Err
+}
+
+// asOAuthError converts an APIError carrying an OAuth error body into an
+// OAuthError, returning err unchanged otherwise.
+func asOAuthError(err error) error {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return err
+	}
+	oauthErr := &OAuthError{Err: err}
+	if json.Unmarshal(apiErr.Body, oauthErr) != nil || oauthErr.Code == "" {
+		return err
+	}
+	return oauthErr
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestAPIErrorFromResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+		case "/groups/existing":
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(`{"status": 409, "message": "Group existing already exists"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"status": 403, "message": "Action is not authorized with current scopes"}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = client.GetUser(ctx, "missing")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected *APIError, got %T", err)
+	}
+	if apiErr.Method != http.MethodGet || apiErr.Path != "users/missing" || apiErr.Message != "Not Found" {
+		t.Errorf("Unexpected APIError contents %+v", apiErr)
+	}
+
+	_, err = client.CreateGroup(ctx, "existing")
+	if !errors.Is(err, ErrConflict) || errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected only ErrConflict, got %v", err)
+	}
+
+	err = client.DeleteUserToken(ctx, "username", "a1")
+	if !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden, got %v", err)
+	}
+}
+
+func TestErrorsRedactTokens(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+	}))
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = client.ValidateToken(ctx, "secrettoken")
+	if !errors.Is(err, ErrNotFound) || strings.Contains(err.Error(), "secrettoken") {
+		t.Errorf("Expected a redacted ErrNotFound, got %v", err)
+	}
+
+	server.Close()
+	_, err = client.ValidateToken(ctx, "secrettoken")
+	var requestErr *RequestError
+	if !errors.As(err, &requestErr) || strings.Contains(err.Error(), "secrettoken") {
+		t.Errorf("Expected a redacted RequestError, got %v", err)
+	}
+}
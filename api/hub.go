@@ -0,0 +1,88 @@
+package api
+
+import (
+	"context"
+)
+
+// UsersAPI manages users and their activity.
+type UsersAPI interface {
+	GetCurrentUser(ctx context.Context, opts ...RequestOption) (*CurrentUserResponse, error)
+	ListUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersResponse, error)
+	ListUsersPaginated(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (*ListUsersPage, error)
+	ListAllUsers(ctx context.Context, options *ListUsersParams, opts ...RequestOption) (ListUsersResponse, error)
+	CreateUsers(ctx context.Context, options *CreateUsersBody, opts ...RequestOption) (*ListUsersResponse, error)
+	GetUser(ctx context.Context, username string, opts ...RequestOption) (*GetUserResponse, error)
+	CreateUser(ctx context.Context, username string, opts ...RequestOption) (*CreateUserResponse, error)
+	DeleteUser(ctx context.Context, username string, opts ...RequestOption) error
+	UpdateUser(ctx context.Context, username string, options *UpdateUserBody, opts ...RequestOption) (*UpdateUserResponse, error)
+	ModifyUser(ctx context.Context, username string, mutate func(user *JupyterHubUser) error, opts ...RequestOption) (*UpdateUserResponse, error)
+	NotifyUserActivity(ctx context.Context, username string, options *UserActivityBody, opts ...RequestOption) error
+}
+
+// GroupsAPI manages groups, their members and their properties.
+type GroupsAPI interface {
+	ListGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsResponse, error)
+	ListGroupsPaginated(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (*ListGroupsPage, error)
+	ListAllGroups(ctx context.Context, options *ListGroupsParams, opts ...RequestOption) (ListGroupsResponse, error)
+	GetGroup(ctx context.Context, groupname string, opts ...RequestOption) (*GetGroupResponse, error)
+	CreateGroup(ctx context.Context, groupname string, opts ...RequestOption) (*CreateGroupResponse, error)
+	DeleteGroup(ctx context.Context, groupname string, opts ...RequestOption) error
+	AddGroupUsers(ctx context.Context, groupname string, options *AddGroupUsersBody, opts ...RequestOption) (*AddGroupUsersResponse, error)
+	RemoveGroupUsers(ctx context.Context, groupname string, options *RemoveGroupUsersBody, opts ...RequestOption) error
+	SetGroupProperties(ctx context.Context, groupname string, properties interface{}, opts ...RequestOption) error
+	PatchGroupProperties(ctx context.Context, groupname string, patch interface{}, opts ...RequestOption) (map[string]interface{}, error)
+}
+
+// TokensAPI manages API tokens and resolves them to identities.
+type TokensAPI interface {
+	ListUserTokens(ctx context.Context, username string, opts ...RequestOption) (*ListTokenResponse, error)
+	CreateUserToken(ctx context.Context, username string, options *CreateUserTokenBody, opts ...RequestOption) (*CreateUserTokenResponse, error)
+	GetUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) (*GetUserTokenResponse, error)
+	DeleteUserToken(ctx context.Context, username string, tokenId string, opts ...RequestOption) error
+	NewAPIToken(ctx context.Context, options *NewTokenBody, opts ...RequestOption) (*NewTokenResponse, error)
+	ValidateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error)
+	AuthenticateToken(ctx context.Context, token string, opts ...RequestOption) (*HubIdentity, error)
+}
+
+// ServersAPI starts, stops and watches users' default and named servers.
+type ServersAPI interface {
+	StartUserServer(ctx context.Context, username string, options interface{}, opts ...RequestOption) error
+	StopUserServer(ctx context.Context, username string, opts ...RequestOption) error
+	StartUserNamedServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) error
+	StopUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error
+	RemoveUserNamedServer(ctx context.Context, username string, serverName string, opts ...RequestOption) error
+	StartServer(ctx context.Context, username string, serverName string, options interface{}, opts ...RequestOption) (StartResult, error)
+	StopServer(ctx context.Context, username string, serverName string, remove bool, opts ...RequestOption) (StopResult, error)
+	StartAndWait(ctx context.Context, username string, serverName string, options interface{}, wait *WaitOptions, opts ...RequestOption) (*JupyterHubServer, error)
+	StopAndWait(ctx context.Context, username string, serverName string, wait *WaitOptions, opts ...RequestOption) (StopResult, error)
+	WatchServerProgress(ctx context.Context, username string, serverName string, fn func(event ProgressEvent) error, opts ...RequestOption) error
+	ServerProgressEvents(ctx context.Context, username string, serverName string, opts ...RequestOption) (<-chan ProgressEvent, <-chan error)
+}
+
+// ProxyAPI reads and updates the hub's proxy routing table.
+type ProxyAPI interface {
+	GetProxyTable(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTableResponse, error)
+	GetProxyTablePaginated(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (*GetProxyTablePage, error)
+	AllProxyRoutes(ctx context.Context, options *GetProxyTableParams, opts ...RequestOption) (GetProxyTableResponse, error)
+	ForceProxySync(ctx context.Context, opts ...RequestOption) error
+	NotifyNewProxy(ctx context.Context, options *NotifyNewProxyBody, opts ...RequestOption) error
+}
+
+// Hub is the JupyterHub REST API as implemented by *ClientConfig. Code that
+// depends on Hub, or on one of the smaller interfaces it embeds, can be given
+// a fake in tests or a client wrapped with Decorate.
+type Hub interface {
+	UsersAPI
+	GroupsAPI
+	TokensAPI
+	ServersAPI
+	ProxyAPI
+
+	GetInfo(ctx context.Context, opts ...RequestOption) (*InfoResponse, error)
+	GetVersion(ctx context.Context, opts ...RequestOption) (*VersionResponse, error)
+	ListServices(ctx context.Context, opts ...RequestOption) (*ListServicesResponse, error)
+	GetService(ctx context.Context, servicename string, opts ...RequestOption) (*GetServiceResponse, error)
+	Shutdown(ctx context.Context, options *ShutdownBody, opts ...RequestOption) error
+}
+
+var _ Hub = (*ClientConfig)(nil)
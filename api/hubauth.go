@@ -0,0 +1,232 @@
+package api
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HubAuthConfig configures a HubAuth. Zero values are replaced with defaults,
+// and a nil Client is created with CreateClient from the JUPYTERHUB_*
+// environment variables set for services.
+type HubAuthConfig struct {
+	Client *ClientConfig
+	// CacheTTL is how long identities are cached, five minutes by default. A
+	// negative value disables caching.
+	CacheTTL time.Duration
+	// CacheMaxSize bounds the number of cached identities, 1000 by default.
+	CacheMaxSize int
+	// AccessScopes grants access to callers holding any one of the scopes,
+	// defaulting to ClientConfig.OAuthAccessScopes. Every authenticated
+	// caller is allowed when both are empty.
+	AccessScopes []string
+}
+
+// HubAuth authenticates requests made to a service with tokens issued by
+// JupyterHub, mirroring jupyterhub.services.auth.HubAuth.
+type HubAuth struct {
+	client       *ClientConfig
+	accessScopes []string
+	cache        *identityCache
+}
+
+type identityContextKey struct{}
+
+func CreateHubAuth(config *HubAuthConfig) (*HubAuth, error) {
+	if config == nil {
+		config = &HubAuthConfig{}
+	}
+
+	client := config.Client
+	if client == nil {
+		var err error
+		client, err = CreateClient(&ClientConfig{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cacheTTL := config.CacheTTL
+	if cacheTTL == 0 {
+		cacheTTL = 5 * time.Minute
+	}
+	cacheMaxSize := config.CacheMaxSize
+	if cacheMaxSize == 0 {
+		cacheMaxSize = 1000
+	}
+	accessScopes := config.AccessScopes
+	if len(accessScopes) == 0 {
+		accessScopes = client.OAuthAccessScopes
+	}
+
+	return &HubAuth{
+		client:       client,
+		accessScopes: accessScopes,
+		cache:        newIdentityCache(cacheTTL, cacheMaxSize),
+	}, nil
+}
+
+// TokenFromRequest extracts a token from the Authorization header, using
+// either the "token" or "Bearer" scheme, or from the token query parameter.
+func TokenFromRequest(r *http.Request) string {
+	if scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " "); ok {
+		if strings.EqualFold(scheme, "token") || strings.EqualFold(scheme, "bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// Authenticate resolves the identity that owns token, answering from the
+// cache when possible. Tokens rejected by the hub return ErrUnauthorized.
+func (h *HubAuth) Authenticate(ctx context.Context, token string) (*HubIdentity, error) {
+	key := cacheKey(token)
+	if identity, ok := h.cache.get(key); ok {
+		return identity, nil
+	}
+
+	identity, err := h.client.AuthenticateToken(ctx, token)
+	if errors.Is(err, ErrUnauthorized) || errors.Is(err, ErrForbidden) || errors.Is(err, ErrNotFound) {
+		return nil, ErrUnauthorized
+	}
+	if err != nil {
+		return nil, err
+	}
+	h.cache.add(key, identity)
+	return identity, nil
+}
+
+// Allowed reports whether identity holds one of the access scopes, either
+// exactly or through a broader scope such as an unfiltered access:services.
+func (h *HubAuth) Allowed(identity *HubIdentity) bool {
+	if len(h.accessScopes) == 0 {
+		return true
+	}
+	for _, required := range h.accessScopes {
+		if missing, err := identity.MissingScopes(required); err == nil && len(missing) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware authenticates every request before passing it to next with the
+// caller's identity available from IdentityFromContext. Requests without a
+// valid token are answered with 401 and callers lacking the access scopes
+// with 403.
+func (h *HubAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := TokenFromRequest(r)
+		if token == "" {
+			writeError(w, http.StatusUnauthorized, "missing token")
+			return
+		}
+
+		identity, err := h.Authenticate(r.Context(), token)
+		if errors.Is(err, ErrUnauthorized) {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "could not authenticate with JupyterHub")
+			return
+		}
+		if !h.Allowed(identity) {
+			writeError(w, http.StatusForbidden, "access to this service is not allowed for "+identity.Name)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), identity)))
+	})
+}
+
+func ContextWithIdentity(ctx context.Context, identity *HubIdentity) context.Context {
+	return context.WithValue(ctx, identityContextKey{}, identity)
+}
+
+func IdentityFromContext(ctx context.Context) (*HubIdentity, bool) {
+	identity, ok := ctx.Value(identityContextKey{}).(*HubIdentity)
+	return identity, ok
+}
+
+// writeError writes the same JSON error model as JupyterHub.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(jupyterhubErrorBody{Status: status, Message: message})
+}
+
+// cacheKey hashes tokens so that they are not kept in memory in plain text.
+func cacheKey(token string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(token))
+}
+
+// identityCache is a least recently used cache of identities with a TTL.
+type identityCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	maxSize int
+	order   *list.List
+	entries map[[sha256.Size]byte]*list.Element
+}
+
+type identityCacheEntry struct {
+	key      [sha256.Size]byte
+	identity *HubIdentity
+	expires  time.Time
+}
+
+func newIdentityCache(ttl time.Duration, maxSize int) *identityCache {
+	return &identityCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		order:   list.New(),
+		entries: map[[sha256.Size]byte]*list.Element{},
+	}
+}
+
+func (c *identityCache) get(key [sha256.Size]byte) (*HubIdentity, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*identityCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry.identity, true
+}
+
+func (c *identityCache) add(key [sha256.Size]byte, identity *HubIdentity) {
+	if c.ttl < 0 || c.maxSize < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &identityCacheEntry{key: key, identity: identity, expires: time.Now().Add(c.ttl)}
+	if element, ok := c.entries[key]; ok {
+		element.Value = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.maxSize {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*identityCacheEntry).key)
+	}
+}
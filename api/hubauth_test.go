@@ -0,0 +1,95 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestHubAuthMiddleware(t *testing.T) {
+	var lookups int32
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&lookups, 1)
+		switch r.Header.Get("Authorization") {
+		case "Bearer usertoken":
+			w.Write([]byte(`{"kind": "user", "name": "username", "scopes": ["access:services!service=my-service"]}`))
+		case "Bearer othertoken":
+			w.Write([]byte(`{"kind": "user", "name": "other", "scopes": []}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer hub.Close()
+
+	client, err := CreateClient(&ClientConfig{
+		ApiToken:          "servicetoken",
+		ApiURL:            hub.URL,
+		OAuthAccessScopes: []string{"access:services!service=my-service"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := CreateHubAuth(&HubAuthConfig{Client: client, CacheTTL: time.Minute, CacheMaxSize: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, ok := IdentityFromContext(r.Context())
+		if !ok {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(identity.Name))
+	}))
+
+	cases := []struct {
+		header string
+		query  string
+		status int
+	}{
+		{"", "", http.StatusUnauthorized},
+		{"token invalid", "", http.StatusUnauthorized},
+		{"token usertoken", "", http.StatusOK},
+		{"Bearer usertoken", "", http.StatusOK},
+		{"", "usertoken", http.StatusOK},
+		{"token othertoken", "", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/services/my-service/?token="+c.query, nil)
+		if c.header != "" {
+			r.Header.Set("Authorization", c.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for header %q query %q, got %d", c.status, c.header, c.query, w.Code)
+		}
+	}
+
+	// usertoken was looked up once and then served from the cache until
+	// othertoken evicted it.
+	if lookups != 3 {
+		t.Errorf("Expected 3 hub lookups, got %d", lookups)
+	}
+}
+
+func TestIdentityCacheExpiry(t *testing.T) {
+	cache := newIdentityCache(time.Millisecond, 10)
+	key := cacheKey("usertoken")
+	cache.add(key, &HubIdentity{Name: "username"})
+	if _, ok := cache.get(key); !ok {
+		t.Fatal("Expected cached identity")
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, ok := cache.get(key); ok {
+		t.Error("Expected cached identity to expire")
+	}
+
+	if _, err := (&HubAuth{cache: cache, client: &ClientConfig{ApiURL: "http://127.0.0.1:1"}}).Authenticate(context.Background(), "usertoken"); err == nil {
+		t.Error("Expected an unreachable hub to fail authentication")
+	}
+}
@@ -0,0 +1,415 @@
+package api
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/utils"
+)
+
+// HubOAuthConfig configures a HubOAuth. Zero values are replaced with
+// defaults derived from the client, which is created from the JUPYTERHUB_*
+// environment variables when nil.
+type HubOAuthConfig struct {
+	Client  *ClientConfig
+	HubAuth *HubAuthConfig
+	// CookieSecret signs the OAuth state cookie and encrypts the session
+	// cookie. A random secret is generated when empty, so sessions do not
+	// survive a restart of the service.
+	CookieSecret []byte
+	// CookieName defaults to the OAuth client id.
+	CookieName string
+	// CookieMaxAge defaults to 14 days, matching the hub's default OAuth
+	// token lifetime.
+	CookieMaxAge time.Duration
+	// CallbackURL defaults to ClientConfig.OAuthCallbackURL and then to
+	// ServicePrefix + "oauth_callback".
+	CallbackURL string
+	// DisablePKCE turns off the PKCE code challenge sent with every login,
+	// for hubs that reject it.
+	DisablePKCE bool
+}
+
+// HubOAuth logs browser users into a service through the hub's OAuth
+// provider, mirroring jupyterhub.services.auth.HubOAuth. Mount
+// CallbackHandler at CallbackPath and wrap the service's handlers with
+// Middleware.
+type HubOAuth struct {
+	client        *ClientConfig
+	auth          *HubAuth
+	cookieName    string
+	cookiePath    string
+	cookieMaxAge  time.Duration
+	callbackURL   string
+	pkce          bool
+	signingKey    []byte
+	encryptionKey []byte
+}
+
+type oauthState struct {
+	State    string `json:"state"`
+	Next     string `json:"next"`
+	Verifier string `json:"verifier,omitempty"`
+}
+
+type oauthSession struct {
+	Token   string `json:"token"`
+	Expires int64  `json:"expires"`
+}
+
+const oauthStateMaxAge = 10 * time.Minute
+
+func CreateHubOAuth(config *HubOAuthConfig) (*HubOAuth, error) {
+	if config == nil {
+		config = &HubOAuthConfig{}
+	}
+
+	client := config.Client
+	if client == nil {
+		var err error
+		client, err = CreateClient(&ClientConfig{})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	authConfig := HubAuthConfig{}
+	if config.HubAuth != nil {
+		authConfig = *config.HubAuth
+	}
+	authConfig.Client = client
+	auth, err := CreateHubAuth(&authConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	cookieName := config.CookieName
+	if cookieName == "" {
+		cookieName = client.ClientId
+	}
+	if cookieName == "" && client.ServiceName != "" {
+		cookieName = "service-" + client.ServiceName
+	}
+	if cookieName == "" {
+		return nil, errors.New("CookieName not set via config or environment variable JUPYTERHUB_CLIENT_ID or JUPYTERHUB_SERVICE_NAME")
+	}
+
+	cookiePath := client.ServicePrefix
+	if cookiePath == "" {
+		cookiePath = "/"
+	}
+
+	cookieMaxAge := config.CookieMaxAge
+	if cookieMaxAge == 0 {
+		cookieMaxAge = 14 * 24 * time.Hour
+	}
+
+	callbackURL := config.CallbackURL
+	if callbackURL == "" {
+		callbackURL = client.OAuthCallbackURL
+	}
+	if callbackURL == "" {
+		callbackURL = strings.TrimSuffix(cookiePath, "/") + "/oauth_callback"
+	}
+
+	secret := config.CookieSecret
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return nil, err
+		}
+	}
+
+	return &HubOAuth{
+		client:        client,
+		auth:          auth,
+		cookieName:    cookieName,
+		cookiePath:    cookiePath,
+		cookieMaxAge:  cookieMaxAge,
+		callbackURL:   callbackURL,
+		pkce:          !config.DisablePKCE,
+		signingKey:    deriveKey(secret, "jupyterhub-oauth-state"),
+		encryptionKey: deriveKey(secret, "jupyterhub-oauth-session"),
+	}, nil
+}
+
+// CallbackPath is the path CallbackHandler must be served at.
+func (o *HubOAuth) CallbackPath() string {
+	if u, err := url.Parse(o.callbackURL); err == nil {
+		return u.Path
+	}
+	return o.callbackURL
+}
+
+// Middleware authenticates requests by token like HubAuth.Middleware, or by
+// the session cookie set by CallbackHandler. Browsers without a session are
+// redirected to log in through the hub; other clients receive a 401.
+func (o *HubOAuth) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := TokenFromRequest(r)
+		if token == "" {
+			token = o.sessionToken(r)
+		}
+		if token == "" {
+			o.unauthenticated(w, r)
+			return
+		}
+
+		identity, err := o.auth.Authenticate(r.Context(), token)
+		if errors.Is(err, ErrUnauthorized) {
+			o.ClearSession(w)
+			o.unauthenticated(w, r)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "could not authenticate with JupyterHub")
+			return
+		}
+		if !o.auth.Allowed(identity) {
+			writeError(w, http.StatusForbidden, "access to this service is not allowed for "+identity.Name)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(ContextWithIdentity(r.Context(), identity)))
+	})
+}
+
+// LoginHandler redirects to the hub to log in, returning to the path in the
+// next query parameter afterwards.
+func (o *HubOAuth) LoginHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		o.redirectToLogin(w, r, r.URL.Query().Get("next"))
+	})
+}
+
+// CallbackHandler completes the login started by Middleware or LoginHandler:
+// it checks the state, exchanges the code for an access token, verifies the
+// user may access the service and stores the token in an encrypted session
+// cookie.
+func (o *HubOAuth) CallbackHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var state oauthState
+		cookie, err := r.Cookie(o.stateCookieName())
+		if err != nil || !o.verify(cookie.Value, &state) {
+			writeError(w, http.StatusBadRequest, "OAuth state missing or invalid, please log in again")
+			return
+		}
+		http.SetCookie(w, o.cookie(r, o.stateCookieName(), "", -1))
+
+		code, err := o.client.ParseOAuthRequest(r, state.State)
+		if errors.Is(err, ErrOAuthStateMismatch) {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		issued := time.Now()
+		token, err := o.client.GetOAuth2Token(r.Context(), &GetOAuth2TokenBody{Code: code, RedirectUri: o.callbackURL, CodeVerifier: state.Verifier})
+		if err != nil {
+			writeError(w, http.StatusForbidden, "could not complete OAuth login with JupyterHub")
+			return
+		}
+
+		identity, err := o.auth.Authenticate(r.Context(), token.AccessToken)
+		if err != nil {
+			writeError(w, http.StatusForbidden, "could not identify user with JupyterHub")
+			return
+		}
+		if !o.auth.Allowed(identity) {
+			writeError(w, http.StatusForbidden, "access to this service is not allowed for "+identity.Name)
+			return
+		}
+
+		expires := issued.Add(o.cookieMaxAge)
+		if expiry := token.Expiry(issued); !expiry.IsZero() && expiry.Before(expires) {
+			expires = expiry
+		}
+		session, err := o.seal(oauthSession{Token: token.AccessToken, Expires: expires.Unix()})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "could not create session")
+			return
+		}
+		http.SetCookie(w, o.cookie(r, o.cookieName, session, int(time.Until(expires)/time.Second)))
+		http.Redirect(w, r, o.safeNext(state.Next), http.StatusFound)
+	})
+}
+
+// ClearSession removes the session cookie, logging the user out of the
+// service but not the hub.
+func (o *HubOAuth) ClearSession(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{Name: o.cookieName, Path: o.cookiePath, MaxAge: -1, HttpOnly: true})
+}
+
+func (o *HubOAuth) unauthenticated(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html") {
+		o.redirectToLogin(w, r, r.URL.RequestURI())
+		return
+	}
+	writeError(w, http.StatusUnauthorized, "missing or invalid credentials")
+}
+
+func (o *HubOAuth) redirectToLogin(w http.ResponseWriter, r *http.Request, next string) {
+	state, err := utils.RandomToken(32)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "could not generate OAuth state")
+		return
+	}
+
+	params := &GetOAuth2EndpointParams{State: state, RedirectUri: o.callbackURL}
+	stored := oauthState{State: state, Next: o.safeNext(next)}
+	if o.pkce {
+		pkce, err := NewPKCE()
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "could not generate PKCE challenge")
+			return
+		}
+		params.CodeChallenge = pkce.Challenge
+		params.CodeChallengeMethod = pkce.Method
+		stored.Verifier = pkce.Verifier
+	}
+
+	authorizeURL, err := o.client.GetOAuth2Endpoint(params)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	cookie, err := o.sign(stored)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "could not store OAuth state")
+		return
+	}
+	http.SetCookie(w, o.cookie(r, o.stateCookieName(), cookie, int(oauthStateMaxAge/time.Second)))
+	http.Redirect(w, r, authorizeURL, http.StatusFound)
+}
+
+func (o *HubOAuth) sessionToken(r *http.Request) string {
+	cookie, err := r.Cookie(o.cookieName)
+	if err != nil {
+		return ""
+	}
+	var session oauthSession
+	if !o.open(cookie.Value, &session) || time.Now().Unix() > session.Expires {
+		return ""
+	}
+	return session.Token
+}
+
+func (o *HubOAuth) stateCookieName() string {
+	return o.cookieName + "-oauth-state"
+}
+
+// safeNext only allows redirects to local paths so that the login flow can
+// not be used as an open redirect.
+func (o *HubOAuth) safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return o.cookiePath
+	}
+	return next
+}
+
+func (o *HubOAuth) cookie(r *http.Request, name string, value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     o.cookiePath,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+func deriveKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose))
+	return mac.Sum(nil)
+}
+
+// sign encodes value as base64 JSON followed by its HMAC-SHA256 signature.
+func (o *HubOAuth) sign(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	mac := hmac.New(sha256.New, o.signingKey)
+	mac.Write([]byte(payload))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
+
+func (o *HubOAuth) verify(signed string, value interface{}) bool {
+	payload, signature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return false
+	}
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, o.signingKey)
+	mac.Write([]byte(payload))
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(data, value) == nil
+}
+
+// seal encrypts value as JSON with AES-256-GCM.
+func (o *HubOAuth) seal(value interface{}) (string, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", err
+	}
+	aead, err := o.aead()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, data, []byte(o.cookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (o *HubOAuth) open(sealed string, value interface{}) bool {
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return false
+	}
+	aead, err := o.aead()
+	if err != nil || len(data) < aead.NonceSize() {
+		return false
+	}
+	plaintext, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(o.cookieName))
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(plaintext, value) == nil
+}
+
+func (o *HubOAuth) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(o.encryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,125 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHubOAuthLoginFlow(t *testing.T) {
+	var challenge string
+	hub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/hub/api/oauth2/authorize":
+			query := r.URL.Query()
+			if query.Get("code_challenge_method") != PKCEMethodS256 {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			challenge = query.Get("code_challenge")
+			redirect := query.Get("redirect_uri") + "?code=thecode&state=" + url.QueryEscape(query.Get("state"))
+			http.Redirect(w, r, redirect, http.StatusFound)
+		case r.URL.Path == "/hub/api/oauth2/token":
+			r.ParseForm()
+			if r.PostForm.Get("code") != "thecode" || r.PostForm.Get("client_secret") != "servicetoken" || pkceChallenge(r.PostForm.Get("code_verifier")) != challenge {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte(`{"access_token": "usertoken", "token_type": "Bearer", "expires_in": 3600}`))
+		case r.URL.Path == "/hub/api/user" && r.Header.Get("Authorization") == "Bearer usertoken":
+			w.Write([]byte(`{"name": "username", "scopes": ["access:services!service=my-service"]}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer hub.Close()
+
+	mux := http.NewServeMux()
+	service := httptest.NewServer(mux)
+	defer service.Close()
+
+	client, err := CreateClient(&ClientConfig{
+		ApiToken:          "servicetoken",
+		ApiURL:            hub.URL + "/hub/api",
+		Host:              hub.URL,
+		BaseURL:           "/",
+		ServiceName:       "my-service",
+		ServicePrefix:     "/services/my-service/",
+		OAuthCallbackURL:  service.URL + "/services/my-service/oauth_callback",
+		OAuthAccessScopes: []string{"access:services!service=my-service"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth, err := CreateHubOAuth(&HubOAuthConfig{Client: client, CookieSecret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux.Handle(oauth.CallbackPath(), oauth.CallbackHandler())
+	mux.Handle("/services/my-service/", oauth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := IdentityFromContext(r.Context())
+		w.Write([]byte("hello " + identity.Name))
+	})))
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+
+	request, _ := http.NewRequest(http.MethodGet, service.URL+"/services/my-service/page?x=1", nil)
+	request.Header.Set("Accept", "text/html")
+	response, err := browser.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	if response.StatusCode != http.StatusOK || string(body) != "hello username" {
+		t.Fatalf("Expected login to complete, got %d %s", response.StatusCode, body)
+	}
+	if response.Request.URL.RequestURI() != "/services/my-service/page?x=1" {
+		t.Errorf("Expected redirect back to the original page, got %v", response.Request.URL)
+	}
+
+	serviceURL, _ := url.Parse(service.URL + "/services/my-service/")
+	for _, cookie := range jar.Cookies(serviceURL) {
+		if strings.Contains(cookie.Value, "usertoken") {
+			t.Errorf("Expected session cookie to be encrypted, got %v", cookie.Value)
+		}
+	}
+
+	response, err = http.Get(service.URL + "/services/my-service/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 for API requests without a session, got %d", response.StatusCode)
+	}
+
+	response, err = browser.Get(service.URL + oauth.CallbackPath() + "?code=thecode&state=forged")
+	if err != nil {
+		t.Fatal(err)
+	}
+	response.Body.Close()
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a callback without state cookie, got %d", response.StatusCode)
+	}
+}
+
+func TestHubOAuthSafeNext(t *testing.T) {
+	oauth := &HubOAuth{cookiePath: "/services/my-service/"}
+	for next, expected := range map[string]string{
+		"/services/my-service/page": "/services/my-service/page",
+		"https://evil.example":      "/services/my-service/",
+		"//evil.example":            "/services/my-service/",
+		"":                          "/services/my-service/",
+	} {
+		if actual := oauth.safeNext(next); actual != expected {
+			t.Errorf("Expected safeNext(%q) to be %q, got %q", next, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAuthenticateToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/authorizations/token/servicetoken":
+			w.Write([]byte(`{"kind": "service", "name": "my-service", "admin": true, "roles": ["admin"]}`))
+		case r.URL.Path == "/user" && r.Header.Get("Authorization") == "Bearer usertoken":
+			w.Write([]byte(`{"name": "username", "groups": ["physics"], "scopes": ["read:users!user=username"], "session_id": "abc"}`))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "servicetoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	service, err := client.ValidateToken(ctx, "servicetoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !service.IsService() || service.Name != "my-service" || !service.Admin {
+		t.Errorf("Unexpected service identity %+v", service)
+	}
+
+	user, err := client.AuthenticateToken(ctx, "usertoken")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Kind != IdentityKindUser || user.Name != "username" || user.Groups[0] != "physics" || user.SessionId != "abc" {
+		t.Errorf("Unexpected user identity %+v", user)
+	}
+
+	if _, err := client.AuthenticateToken(ctx, "invalid"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for an invalid token, got %v", err)
+	}
+}
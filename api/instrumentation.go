@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// Instrumentation observes every request made by a client, for example to
+// record metrics or trace spans. Start is called before the first attempt
+// and the context it returns is used for the request and passed to Finish,
+// which is called once the request has completed, including any retries.
+// Both may be called concurrently.
+type Instrumentation interface {
+	Start(ctx context.Context, info RequestInfo) context.Context
+	Finish(ctx context.Context, info RequestInfo, result RequestResult)
+}
+
+// RequestInfo describes a request to the hub. Operation is the client method
+// making it, such as ListUsers, and Path is its templated path relative to
+// the API, such as "users/{name}". Requests made with Request directly have
+// no Operation and their literal path without the query, with any token
+// redacted.
+type RequestInfo struct {
+	Operation string
+	Method    string
+	Path      string
+}
+
+// RequestResult is the outcome of a request. StatusCode is the status of the
+// last response and zero if none was received.
+type RequestResult struct {
+	StatusCode int
+	Duration   time.Duration
+	Attempts   int
+	Err        error
+}
+
+func requestInfo(method string, path string, options *requestOptions) RequestInfo {
+	info := RequestInfo{Operation: options.operation, Method: method, Path: options.template}
+	if info.Operation == "" {
+		info.Path, _, _ = strings.Cut(redact.Path(path), "?")
+	}
+	return info
+}
+
+// responseStatus is the status of resp, or of the response an *APIError was
+// created from.
+func responseStatus(resp *http.Response, err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	if resp != nil {
+		return resp.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+type recordingInstrumentation struct {
+	mu       sync.Mutex
+	started  []RequestInfo
+	finished []RequestResult
+	spans    []interface{}
+}
+
+func (r *recordingInstrumentation) Start(ctx context.Context, info RequestInfo) context.Context {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started = append(r.started, info)
+	return context.WithValue(ctx, spanKey{}, info.Operation)
+}
+
+func (r *recordingInstrumentation) Finish(ctx context.Context, info RequestInfo, result RequestResult) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.finished = append(r.finished, result)
+	r.spans = append(r.spans, ctx.Value(spanKey{}))
+}
+
+func TestInstrumentation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/alice":
+			w.Write([]byte(`{"name": "alice"}`))
+		case "/users/alice/servers/lab":
+			w.WriteHeader(http.StatusCreated)
+		case "/authorizations/token/secret":
+			w.Write([]byte(`{"kind": "user", "name": "alice"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	instrumentation := &recordingInstrumentation{}
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL, Instrumentation: instrumentation})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	client.GetUser(ctx, "alice")
+	client.StartServer(ctx, "alice", "lab", nil)
+	client.ValidateToken(ctx, "secret")
+	client.GetGroup(ctx, "missing")
+	client.Request(ctx, http.MethodGet, "groups/missing?include=1", "application/json", nil)
+
+	expected := []RequestInfo{
+		{Operation: "GetUser", Method: http.MethodGet, Path: "users/{name}"},
+		{Operation: "StartServer", Method: http.MethodPost, Path: "users/{name}/servers/{server_name}"},
+		{Operation: "ValidateToken", Method: http.MethodGet, Path: "authorizations/token/{token}"},
+		{Operation: "GetGroup", Method: http.MethodGet, Path: "groups/{name}"},
+		{Method: http.MethodGet, Path: "groups/missing"},
+	}
+	if !reflect.DeepEqual(instrumentation.started, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, instrumentation.started)
+	}
+
+	var statuses []int
+	for _, result := range instrumentation.finished {
+		statuses = append(statuses, result.StatusCode)
+		if result.Attempts != 1 || result.Duration <= 0 {
+			t.Errorf("Unexpected result %+v", result)
+		}
+	}
+	if expected := []int{200, 201, 200, 404, 404}; !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Expected statuses %v, got %v", expected, statuses)
+	}
+	if instrumentation.finished[3].Err == nil {
+		t.Errorf("Expected the failed request's error to be reported")
+	}
+	if expected := []interface{}{"GetUser", "StartServer", "ValidateToken", "GetGroup", ""}; !reflect.DeepEqual(instrumentation.spans, expected) {
+		t.Errorf("Expected Finish to receive the context from Start, got %v", instrumentation.spans)
+	}
+}
@@ -0,0 +1,146 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WaitOptions controls how StartAndWait and StopAndWait wait for a server.
+type WaitOptions struct {
+	// Timeout bounds the whole call when set.
+	Timeout time.Duration
+	// PollInterval is the delay between GetUser calls, one second by default.
+	PollInterval time.Duration
+	// UseProgress waits on the server's progress event stream instead of
+	// polling GetUser.
+	UseProgress bool
+	// StopOnCancel stops the server if the context is cancelled or the
+	// timeout expires before it is ready, so no half-spawned server is left
+	// behind. JupyterHub only stops a server once its spawn has finished, so
+	// this may wait for the spawn for up to two minutes after cancellation.
+	StopOnCancel bool
+}
+
+const stopOnCancelTimeout = 2 * time.Minute
+
+func (w *WaitOptions) pollInterval() time.Duration {
+	if w.PollInterval <= 0 {
+		return time.Second
+	}
+	return w.PollInterval
+}
+
+// StartAndWait starts the default server of username, or the named server
+// serverName when it is set, and blocks until it is ready. A server that is
+// already running or pending is waited on rather than reported as an error.
+func (c *ClientConfig) StartAndWait(ctx context.Context, username string, serverName string, options interface{}, wait *WaitOptions, opts ...RequestOption) (*JupyterHubServer, error) {
+	if wait == nil {
+		wait = &WaitOptions{}
+	}
+	if wait.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wait.Timeout)
+		defer cancel()
+	}
+
+	// JupyterHub answers 400 when the server is already running or pending,
+	// which waitForServer resolves by looking at the server itself.
+	_, startErr := c.StartServer(ctx, username, serverName, options, opts...)
+	var server *JupyterHubServer
+	err := startErr
+	if startErr == nil || errors.Is(startErr, ErrBadRequest) {
+		server, err = c.waitForServer(ctx, username, serverName, wait, opts)
+		if errors.Is(err, ErrServerNotRunning) && startErr != nil {
+			err = startErr
+		}
+	}
+
+	if wait.StopOnCancel && (errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)) {
+		stopCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopOnCancelTimeout)
+		defer cancel()
+		err = errors.Join(err, c.stopAfterSpawn(stopCtx, username, serverName, wait, opts))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return server, nil
+}
+
+// stopAfterSpawn stops a server whose spawn may still be pending. JupyterHub
+// answers 400 to a stop while the spawn is pending, so the stop is retried
+// until the spawn has finished.
+func (c *ClientConfig) stopAfterSpawn(ctx context.Context, username string, serverName string, wait *WaitOptions, opts []RequestOption) error {
+	for {
+		_, err := c.StopServer(ctx, username, serverName, false, opts...)
+		if !errors.Is(err, ErrBadRequest) {
+			return err
+		}
+		if !sleepContext(ctx, wait.pollInterval()) {
+			return err
+		}
+	}
+}
+
+func (c *ClientConfig) waitForServer(ctx context.Context, username string, serverName string, wait *WaitOptions, opts []RequestOption) (*JupyterHubServer, error) {
+	if wait.UseProgress {
+		err := c.WatchServerProgress(ctx, username, serverName, func(event ProgressEvent) error {
+			return nil
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for {
+		user, err := c.GetUser(ctx, username, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		server, ok := user.Servers[serverName]
+		if ok && server.Ready {
+			return &server, nil
+		}
+		if !ok || server.Stopped || server.Pending == PendingNone {
+			return nil, ErrServerNotRunning
+		}
+
+		if !sleepContext(ctx, wait.pollInterval()) {
+			return nil, contextError(ctx)
+		}
+	}
+}
+
+// StopAndWait stops the default server of username, or the named server
+// serverName when it is set, and blocks until the hub reports it stopped.
+func (c *ClientConfig) StopAndWait(ctx context.Context, username string, serverName string, wait *WaitOptions, opts ...RequestOption) (StopResult, error) {
+	if wait == nil {
+		wait = &WaitOptions{}
+	}
+	if wait.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wait.Timeout)
+		defer cancel()
+	}
+
+	result, err := c.StopServer(ctx, username, serverName, false, opts...)
+	if err != nil || result != StopResultPending {
+		return result, err
+	}
+
+	for {
+		if !sleepContext(ctx, wait.pollInterval()) {
+			return result, contextError(ctx)
+		}
+
+		user, err := c.GetUser(ctx, username, opts...)
+		if err != nil {
+			return result, err
+		}
+		server, ok := user.Servers[serverName]
+		if !ok || (server.Pending == PendingNone && (server.Stopped || !server.Ready)) {
+			return StopResultStopped, nil
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSpawner answers server start, stop and user requests for a single
+// server that becomes ready after readyAfter polls. Like JupyterHub it
+// refuses to stop the server while the spawn is pending.
+type fakeSpawner struct {
+	mu         sync.Mutex
+	readyAfter int
+	polls      int
+	running    bool
+	stops      int
+	rejected   int
+}
+
+func (f *fakeSpawner) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPost:
+		f.running = true
+		w.WriteHeader(http.StatusAccepted)
+	case http.MethodDelete:
+		if f.running && (f.readyAfter < 0 || f.polls < f.readyAfter) {
+			f.rejected++
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"status": 400, "message": "username:lab is pending spawn, please wait"}`))
+			return
+		}
+		f.running = false
+		f.stops++
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodGet:
+		user := JupyterHubUser{Name: "username", Servers: map[string]JupyterHubServer{}}
+		if f.running {
+			f.polls++
+			server := JupyterHubServer{Name: "lab", Pending: PendingSpawn}
+			if f.readyAfter >= 0 && f.polls >= f.readyAfter {
+				server = JupyterHubServer{Name: "lab", Ready: true, Url: "/user/username/lab/"}
+			}
+			user.Servers["lab"] = server
+		}
+		json.NewEncoder(w).Encode(user)
+	}
+}
+
+func TestStartAndWait(t *testing.T) {
+	spawner := &fakeSpawner{readyAfter: 3}
+	server := httptest.NewServer(spawner)
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := client.StartAndWait(context.Background(), "username", "lab", nil, &WaitOptions{PollInterval: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Ready || result.Url != "/user/username/lab/" || spawner.polls != 3 {
+		t.Errorf("Expected ready server after 3 polls, got %+v after %d", result, spawner.polls)
+	}
+}
+
+func TestStartAndWaitStopOnCancel(t *testing.T) {
+	spawner := &fakeSpawner{readyAfter: 20}
+	server := httptest.NewServer(spawner)
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wait := &WaitOptions{Timeout: 50 * time.Millisecond, PollInterval: 5 * time.Millisecond, StopOnCancel: true}
+	_, err = client.StartAndWait(context.Background(), "username", "lab", nil, wait)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if spawner.stops != 1 || spawner.running || spawner.rejected == 0 {
+		t.Errorf("Expected the server to be stopped once its spawn finished, got %d stops after %d rejected", spawner.stops, spawner.rejected)
+	}
+}
+
+func TestStopServer(t *testing.T) {
+	var mu sync.Mutex
+	var removed bool
+	polls, stopped := 0, 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Method == http.MethodDelete && (r.URL.Path == "/users/username/server" || r.URL.Path == "/users/limited/server" || r.URL.Path == "/users/noservers/server"):
+			stopped++
+			w.WriteHeader(http.StatusNoContent)
+		case r.URL.Path == "/users/limited":
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"status": 403, "message": "Action is not authorized with current scopes; requires any of [read:users]"}`))
+		case r.URL.Path == "/users/noservers":
+			w.Write([]byte(`{"name": "noservers"}`))
+		case r.Method == http.MethodDelete && r.URL.Path == "/users/username/servers/old":
+			var body map[string]bool
+			json.NewDecoder(r.Body).Decode(&body)
+			removed = body["remove"]
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == http.MethodDelete:
+			w.WriteHeader(http.StatusAccepted)
+		default:
+			polls++
+			lab := JupyterHubServer{Name: "lab", Ready: true}
+			if polls > 2 {
+				lab = JupyterHubServer{Name: "lab", Pending: PendingStop}
+			}
+			if polls > 4 {
+				lab = JupyterHubServer{Name: "lab", Stopped: true}
+			}
+			old := JupyterHubServer{Name: "old", Stopped: true}
+			json.NewEncoder(w).Encode(JupyterHubUser{Name: "username", Servers: map[string]JupyterHubServer{"lab": lab, "old": old}})
+		}
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if result, err := client.StopServer(ctx, "username", "", false); err != nil || result != StopResultAlreadyStopped || stopped != 1 {
+		t.Errorf("Expected StopResultAlreadyStopped, got %v %v after %d stops", result, err, stopped)
+	}
+
+	// Tokens that may not read the user's servers still stop them.
+	for _, username := range []string{"limited", "noservers"} {
+		if result, err := client.StopServer(ctx, username, "", false); err != nil || result != StopResultStopped {
+			t.Errorf("Expected StopResultStopped for %s, got %v %v", username, result, err)
+		}
+	}
+	if stopped != 3 {
+		t.Errorf("Expected every stop to be sent, got %d stops", stopped)
+	}
+
+	if result, err := client.StopServer(ctx, "username", "old", true); err != nil || result != StopResultAlreadyStopped || !removed {
+		t.Errorf("Expected stopped named server to be removed, got %v %v", result, err)
+	}
+
+	polls = 0
+	result, err := client.StopAndWait(ctx, "username", "lab", &WaitOptions{PollInterval: time.Millisecond})
+	if err != nil || result != StopResultStopped || polls != 5 {
+		t.Errorf("Expected StopResultStopped after 5 polls, got %v %v after %d", result, err, polls)
+	}
+}
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// maxLoggedBody is the number of bytes of a request or response body that
+// are logged at the debug level.
+const maxLoggedBody = 4096
+
+// logRequest logs a completed Request to ClientConfig.Logger with its
+// status, latency and number of retries. Headers and bodies are logged at the
+// debug level with credentials redacted.
+func (c *ClientConfig) logRequest(ctx context.Context, method string, path string, contentType string, requestBody []byte, options *requestOptions, resp *http.Response, responseBody []byte, attempts int, latency time.Duration, err error) {
+	if c.Logger == nil {
+		return
+	}
+
+	status := responseStatus(resp, err)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		responseBody = apiErr.Body
+	}
+
+	loggedPath := redact.Path(path)
+	attrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("path", loggedPath),
+		slog.Int("status", status),
+		slog.Duration("latency", latency),
+		slog.Int("retries", max(attempts-1, 0)),
+	}
+	level := slog.LevelInfo
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	c.Logger.LogAttrs(ctx, level, "jupyterhub request", attrs...)
+
+	if !c.Logger.Enabled(ctx, slog.LevelDebug) {
+		return
+	}
+	debugAttrs := []slog.Attr{
+		slog.String("method", method),
+		slog.String("path", loggedPath),
+		slog.Any("request_header", redactHeader(options.requestHeader(contentType))),
+		slog.String("request_body", redactBody(contentType, requestBody)),
+	}
+	responseContentType := ""
+	if resp != nil {
+		responseContentType = resp.Header.Get("Content-Type")
+		debugAttrs = append(debugAttrs, slog.Any("response_header", redactHeader(resp.Header)))
+	}
+	if responseBody != nil {
+		debugAttrs = append(debugAttrs, slog.String("response_body", redactBody(responseContentType, responseBody)))
+	}
+	c.Logger.LogAttrs(ctx, slog.LevelDebug, "jupyterhub request body", debugAttrs...)
+}
+
+func redactHeader(header http.Header) http.Header {
+	result := make(http.Header, len(header))
+	for key, values := range header {
+		if redact.Header(key) {
+			values = []string{redact.Placeholder}
+		}
+		result[key] = values
+	}
+	return result
+}
+
+// redactBody returns body for logging with the values of credential fields
+// hidden in JSON and form encoded bodies, truncated to maxLoggedBody.
+func redactBody(contentType string, body []byte) string {
+	text := string(body)
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(text); err == nil {
+			for key := range values {
+				if redact.Field(key) {
+					values[key] = []string{redact.Placeholder}
+				}
+			}
+			text = values.Encode()
+		}
+	} else {
+		var value interface{}
+		if json.Unmarshal(body, &value) == nil {
+			redactJSON(value)
+			if data, err := json.Marshal(value); err == nil {
+				text = string(data)
+			}
+		}
+	}
+	if len(text) > maxLoggedBody {
+		text = text[:maxLoggedBody] + "...(truncated)"
+	}
+	return text
+}
+
+func redactJSON(value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if redact.Field(key) && field != nil && field != "" {
+				v[key] = redact.Placeholder
+				continue
+			}
+			redactJSON(field)
+		}
+	case []interface{}:
+		for _, item := range v {
+			redactJSON(item)
+		}
+	}
+}
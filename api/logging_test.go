@@ -0,0 +1,88 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestLogging(t *testing.T) {
+	failures := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users":
+			if failures > 0 {
+				failures--
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`[{"name": "alice"}]`))
+		case "/authorizations/token/secrettoken":
+			w.Write([]byte(`{"kind": "user", "name": "alice"}`))
+		case "/authorizations/token":
+			w.Write([]byte(`{"token": "newtoken"}`))
+		case "/oauth2/token":
+			w.Write([]byte(`{"access_token": "accesstoken", "token_type": "Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	var logs bytes.Buffer
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.RetryNonIdempotent = true
+	client, err := CreateClient(&ClientConfig{
+		ApiToken:    "usertoken",
+		ApiURL:      server.URL,
+		ServiceName: "announcement",
+		RetryPolicy: policy,
+		Logger:      slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.CreateUsers(ctx, &CreateUsersBody{Usernames: []string{"alice"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ValidateToken(ctx, "secrettoken"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.NewAPIToken(ctx, &NewTokenBody{Username: "alice", Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetOAuth2Token(ctx, &GetOAuth2TokenBody{Code: "authcode", ClientSecret: "clientsecret", RedirectUri: "http://localhost/callback"}); err != nil {
+		t.Fatal(err)
+	}
+	client.GetUser(ctx, "missing")
+
+	output := logs.String()
+	for _, secret := range []string{"usertoken", "secrettoken", "newtoken", "hunter2", "authcode", "clientsecret", "accesstoken"} {
+		if strings.Contains(output, secret) {
+			t.Errorf("Expected %q to be redacted from logs:\n%s", secret, output)
+		}
+	}
+	for _, expected := range []string{
+		`level=INFO msg="jupyterhub request" method=POST path=users status=201`,
+		"retries=1",
+		"path=authorizations/token/[REDACTED]",
+		`level=WARN msg="jupyterhub request" method=GET path=users/missing status=404`,
+		`level=DEBUG msg="jupyterhub request body" method=POST path=users`,
+		`request_body="{\"admin\":false,\"usernames\":[\"alice\"]}"`,
+		"Authorization:[[REDACTED]]",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected logs to contain %q:\n%s", expected, output)
+		}
+	}
+}
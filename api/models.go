@@ -1,9 +1,15 @@
 package api
 
 import (
+	"encoding/json"
 	"fmt"
+	"log/slog"
+	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
+	"strings"
+	"time"
 )
 
 const (
@@ -12,6 +18,14 @@ const (
 	ListUsersStateReady    = "ready"
 )
 
+type PendingAction string
+
+const (
+	PendingNone  PendingAction = ""
+	PendingSpawn PendingAction = "spawn"
+	PendingStop  PendingAction = "stop"
+)
+
 type ClientConfig struct {
 	ApiToken                 string
 	ServiceName              string
@@ -23,6 +37,21 @@ type ClientConfig struct {
 	OAuthAccessScopes        []string
 	OAuthClientAllowedScopes []string
 	ClientId                 string
+	Host                     string
+	OAuthCallbackURL         string
+	RetryPolicy              *RetryPolicy
+	HTTPClient               *http.Client
+	Transport                http.RoundTripper
+	Middleware               []Middleware
+	// Logger receives a record for every request made by the client, and
+	// redacted headers and bodies at the debug level. Nothing is logged when
+	// nil.
+	Logger *slog.Logger
+	// Instrumentation, when set, is notified of the start and end of every
+	// request.
+	Instrumentation Instrumentation
+
+	httpClient *http.Client
 }
 
 type VersionResponse struct {
@@ -48,16 +77,40 @@ type InfoResponse struct {
 }
 
 type JupyterHubServer struct {
-	Name         string `json:"name"`
-	Ready        bool   `json:"ready"`
-	Stopped      bool   `json:"stopped"`
-	Pending      string `json:"pending"`
-	Url          string `json:"url"`
-	ProgressUrl  string `json:"progress_url"`
-	Started      string `json:"started"`
-	LastActivity string `json:"last_activity"`
-	State        interface{}
-	UserOptions  interface{}
+	Name         string        `json:"name"`
+	Ready        bool          `json:"ready"`
+	Stopped      bool          `json:"stopped"`
+	Pending      PendingAction `json:"pending"`
+	Url          string        `json:"url"`
+	ProgressUrl  string        `json:"progress_url"`
+	Started      *time.Time    `json:"started"`
+	LastActivity *time.Time    `json:"last_activity"`
+	State        interface{}   `json:"state"`
+	UserOptions  interface{}   `json:"user_options"`
+}
+
+type StartResult int
+
+const (
+	StartResultStarted StartResult = iota + 1
+	StartResultPending
+)
+
+type StopResult int
+
+const (
+	StopResultStopped StopResult = iota + 1
+	StopResultPending
+	StopResultAlreadyStopped
+)
+
+type ProgressEvent struct {
+	Progress    int    `json:"progress"`
+	Message     string `json:"message"`
+	HtmlMessage string `json:"html_message"`
+	Ready       bool   `json:"ready"`
+	Failed      bool   `json:"failed"`
+	Url         string `json:"url"`
 }
 
 type JupyterHubUser struct {
@@ -68,38 +121,85 @@ type JupyterHubUser struct {
 	Roles        []string                    `json:"roles"`
 	Groups       []string                    `json:"groups"`
 	Server       string                      `json:"server"`
-	Pending      string                      `json:"pending"`
-	LastActivity string                      `json:"last_activitiy"`
+	Pending      PendingAction               `json:"pending"`
+	LastActivity *time.Time                  `json:"last_activity"`
 	Servers      map[string]JupyterHubServer `json:"servers"`
-	AuthState    interface{}
+	AuthState    interface{}                 `json:"auth_state"`
 }
 
 type CurrentUserResponse JupyterHubUser
 
+type Pagination struct {
+	Offset int             `json:"offset"`
+	Limit  int             `json:"limit"`
+	Total  int             `json:"total"`
+	Next   *PaginationNext `json:"next"`
+}
+
+type PaginationNext struct {
+	Offset int    `json:"offset"`
+	Limit  int    `json:"limit"`
+	Url    string `json:"url"`
+}
+
+func validatePagination(offset int, limit int) error {
+	if offset < 0 {
+		return fmt.Errorf("invalid offset %d must not be negative", offset)
+	}
+	if limit < 0 {
+		return fmt.Errorf("invalid limit %d must not be negative", limit)
+	}
+	return nil
+}
+
 type ListUsersParams struct {
 	State                 string
+	NameFilter            string
 	Offset                int
 	Limit                 int
 	IncludeStoppedServers bool
 }
 
+func (r *ListUsersParams) Validate() error {
+	switch r.State {
+	case "", ListUsersStateInactive, ListUsersStateActive, ListUsersStateReady:
+	default:
+		return fmt.Errorf("invalid state %q must be one of %q, %q or %q", r.State, ListUsersStateInactive, ListUsersStateActive, ListUsersStateReady)
+	}
+	return validatePagination(r.Offset, r.Limit)
+}
+
 func (r *ListUsersParams) Encode() string {
+	return r.values().Encode()
+}
+
+func (r *ListUsersParams) values() url.Values {
 	v := url.Values{}
-	if r.State == ListUsersStateInactive || r.State == ListUsersStateActive || r.State == ListUsersStateReady {
+	if r.State != "" {
 		v.Set("state", r.State)
 	}
+	if r.NameFilter != "" {
+		v.Set("name_filter", r.NameFilter)
+	}
 	if r.Offset != 0 {
 		v.Set("offset", fmt.Sprint(r.Offset))
 	}
 	if r.Limit != 0 {
 		v.Set("limit", fmt.Sprint(r.Limit))
 	}
-	v.Set("include_stopped_servers", strconv.FormatBool(r.IncludeStoppedServers))
-	return v.Encode()
+	if r.IncludeStoppedServers {
+		v.Set("include_stopped_servers", strconv.FormatBool(r.IncludeStoppedServers))
+	}
+	return v
 }
 
 type ListUsersResponse []JupyterHubUser
 
+type ListUsersPage struct {
+	Items      ListUsersResponse `json:"items"`
+	Pagination *Pagination       `json:"_pagination"`
+}
+
 type CreateUsersBody struct {
 	Usernames []string `json:"usernames"`
 	Admin     bool     `json:"admin"`
@@ -111,34 +211,95 @@ type GetUserResponse JupyterHubUser
 
 type CreateUserResponse JupyterHubUser
 
+// UpdateUserBody only serializes the fields that have been set, so renaming a
+// user does not also change their admin status. SetAuthState(nil) clears the
+// auth_state of the user.
 type UpdateUserBody struct {
-	Name  string `json:"name"`
-	Admin bool   `json:"admin"`
+	Name      *string     `json:"name,omitempty"`
+	Admin     *bool       `json:"admin,omitempty"`
+	AuthState interface{} `json:"-"`
+
+	authStateSet bool
+}
+
+func (b UpdateUserBody) MarshalJSON() ([]byte, error) {
+	body := struct {
+		Name      *string      `json:"name,omitempty"`
+		Admin     *bool        `json:"admin,omitempty"`
+		AuthState *interface{} `json:"auth_state,omitempty"`
+	}{Name: b.Name, Admin: b.Admin}
+	if b.authStateSet || b.AuthState != nil {
+		body.AuthState = &b.AuthState
+	}
+	return json.Marshal(body)
+}
+
+func (b *UpdateUserBody) SetName(name string) *UpdateUserBody {
+	b.Name = &name
+	return b
+}
+
+func (b *UpdateUserBody) SetAdmin(admin bool) *UpdateUserBody {
+	b.Admin = &admin
+	return b
+}
+
+func (b *UpdateUserBody) SetAuthState(authState interface{}) *UpdateUserBody {
+	b.AuthState = authState
+	b.authStateSet = true
+	return b
+}
+
+func (b *UpdateUserBody) IsEmpty() bool {
+	return b.Name == nil && b.Admin == nil && b.AuthState == nil && !b.authStateSet
 }
 
 type UpdateUserResponse JupyterHubUser
 
 type UserActivityBody struct {
-	LastActivity string            `json:"last_activity"`
-	Servers      map[string]string `json:"servers"`
+	LastActivity *time.Time                `json:"last_activity,omitempty"`
+	Servers      map[string]ServerActivity `json:"servers,omitempty"`
+}
+
+type ServerActivity struct {
+	LastActivity time.Time `json:"last_activity"`
 }
 
 type JupyterHubToken struct {
-	Token        string   `json:"token"`
-	Id           string   `json:"id"`
-	User         string   `json:"user"`
-	Service      string   `json:"service"`
-	Roles        []string `json:"roles"`
-	Scopes       []string `json:"scopes"`
-	Note         string   `json:"note"`
-	Created      string   `json:"created"`
-	ExpiresAt    string   `json:"expires_at"`
-	LastActivity string   `json:"last_activity"`
-	SessionId    string   `json:"session_id"`
+	Token        string     `json:"token"`
+	Id           string     `json:"id"`
+	User         string     `json:"user"`
+	Service      string     `json:"service"`
+	Roles        []string   `json:"roles"`
+	Scopes       []string   `json:"scopes"`
+	Note         string     `json:"note"`
+	Created      time.Time  `json:"created"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	LastActivity *time.Time `json:"last_activity"`
+	SessionId    string     `json:"session_id"`
 }
 
 type ListTokenResponse []JupyterHubToken
 
+// UnmarshalJSON accepts the {"api_tokens": [...]} object returned by
+// JupyterHub as well as a bare array of tokens.
+func (r *ListTokenResponse) UnmarshalJSON(data []byte) error {
+	var tokens []JupyterHubToken
+	if err := json.Unmarshal(data, &tokens); err == nil {
+		*r = tokens
+		return nil
+	}
+
+	var envelope struct {
+		ApiTokens []JupyterHubToken `json:"api_tokens"`
+	}
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return err
+	}
+	*r = envelope.ApiTokens
+	return nil
+}
+
 type CreateUserTokenBody struct {
 	ExpiresIn int      `json:"expires_in"`
 	Note      string   `json:"note"`
@@ -151,19 +312,31 @@ type CreateUserTokenResponse JupyterHubToken
 type GetUserTokenResponse JupyterHubToken
 
 type ListGroupsParams struct {
-	Offset int
-	Limit  int
+	NameFilter string
+	Offset     int
+	Limit      int
+}
+
+func (r *ListGroupsParams) Validate() error {
+	return validatePagination(r.Offset, r.Limit)
 }
 
 func (r *ListGroupsParams) Encode() string {
+	return r.values().Encode()
+}
+
+func (r *ListGroupsParams) values() url.Values {
 	v := url.Values{}
+	if r.NameFilter != "" {
+		v.Set("name_filter", r.NameFilter)
+	}
 	if r.Offset != 0 {
 		v.Set("offset", fmt.Sprint(r.Offset))
 	}
 	if r.Limit != 0 {
 		v.Set("limit", fmt.Sprint(r.Limit))
 	}
-	return v.Encode()
+	return v
 }
 
 type JupyterHubGroup struct {
@@ -175,6 +348,11 @@ type JupyterHubGroup struct {
 
 type ListGroupsResponse []JupyterHubGroup
 
+type ListGroupsPage struct {
+	Items      ListGroupsResponse `json:"items"`
+	Pagination *Pagination        `json:"_pagination"`
+}
+
 type GetGroupResponse JupyterHubGroup
 
 type CreateGroupResponse JupyterHubGroup
@@ -202,6 +380,28 @@ type JupyterHubService struct {
 
 type ListServicesResponse []JupyterHubService
 
+// UnmarshalJSON accepts the object keyed by service name returned by
+// JupyterHub, ordering the services by name, as well as a bare array.
+func (r *ListServicesResponse) UnmarshalJSON(data []byte) error {
+	var services []JupyterHubService
+	if err := json.Unmarshal(data, &services); err == nil {
+		*r = services
+		return nil
+	}
+
+	var byName map[string]JupyterHubService
+	if err := json.Unmarshal(data, &byName); err != nil {
+		return err
+	}
+	services = make([]JupyterHubService, 0, len(byName))
+	for _, service := range byName {
+		services = append(services, service)
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	*r = services
+	return nil
+}
+
 type GetServiceResponse JupyterHubService
 
 type GetProxyTableParams struct {
@@ -209,7 +409,15 @@ type GetProxyTableParams struct {
 	Limit  int `json:"limit"`
 }
 
+func (r *GetProxyTableParams) Validate() error {
+	return validatePagination(r.Offset, r.Limit)
+}
+
 func (r *GetProxyTableParams) Encode() string {
+	return r.values().Encode()
+}
+
+func (r *GetProxyTableParams) values() url.Values {
 	v := url.Values{}
 	if r.Offset != 0 {
 		v.Set("offset", fmt.Sprint(r.Offset))
@@ -217,7 +425,7 @@ func (r *GetProxyTableParams) Encode() string {
 	if r.Limit != 0 {
 		v.Set("limit", fmt.Sprint(r.Limit))
 	}
-	return v.Encode()
+	return v
 }
 
 type JupyterHubProxyRoute struct {
@@ -228,6 +436,11 @@ type JupyterHubProxyRoute struct {
 
 type GetProxyTableResponse map[string]JupyterHubProxyRoute
 
+type GetProxyTablePage struct {
+	Items      GetProxyTableResponse `json:"items"`
+	Pagination *Pagination           `json:"_pagination"`
+}
+
 type NotifyNewProxyBody struct {
 	Ip        string `json:"ip"`
 	Port      string `json:"port"`
@@ -244,12 +457,36 @@ type NewTokenResponse struct {
 	Token string `json:"token"`
 }
 
+const (
+	IdentityKindUser    = "user"
+	IdentityKindService = "service"
+)
+
+// HubIdentity is the user or service a token belongs to.
+type HubIdentity struct {
+	Kind      string   `json:"kind"`
+	Name      string   `json:"name"`
+	Admin     bool     `json:"admin"`
+	Groups    []string `json:"groups"`
+	Roles     []string `json:"roles"`
+	Scopes    []string `json:"scopes"`
+	SessionId string   `json:"session_id"`
+}
+
+func (i *HubIdentity) IsService() bool {
+	return i.Kind == IdentityKindService
+}
+
 type GetOAuth2EndpointParams struct {
 	ClientId     string
 	ResponseType string
 	State        string
 	RedirectUri  string
-	Scope        string
+	Scope        []string
+	// CodeChallenge and CodeChallengeMethod enable PKCE, see NewPKCE.
+	// CodeChallengeMethod defaults to S256 when CodeChallenge is set.
+	CodeChallenge       string
+	CodeChallengeMethod string
 }
 
 func (p *GetOAuth2EndpointParams) Encode() string {
@@ -258,8 +495,16 @@ func (p *GetOAuth2EndpointParams) Encode() string {
 	v.Set("response_type", p.ResponseType)
 	v.Set("state", p.State)
 	v.Set("redirect_uri", p.RedirectUri)
-	if p.Scope != "" {
-		v.Set("scope", p.Scope)
+	if len(p.Scope) != 0 {
+		v.Set("scope", strings.Join(p.Scope, " "))
+	}
+	if p.CodeChallenge != "" {
+		method := p.CodeChallengeMethod
+		if method == "" {
+			method = PKCEMethodS256
+		}
+		v.Set("code_challenge", p.CodeChallenge)
+		v.Set("code_challenge_method", method)
 	}
 	return v.Encode()
 }
@@ -270,6 +515,8 @@ type GetOAuth2TokenBody struct {
 	GrantType    string `json:"grant_type"`
 	Code         string `json:"code"`
 	RedirectUri  string `json:"redirect_uri"`
+	CodeVerifier string `json:"code_verifier"`
+	RefreshToken string `json:"refresh_token"`
 }
 
 func (p *GetOAuth2TokenBody) Encode() string {
@@ -277,14 +524,59 @@ func (p *GetOAuth2TokenBody) Encode() string {
 	v.Set("client_id", p.ClientId)
 	v.Set("client_secret", p.ClientSecret)
 	v.Set("grant_type", p.GrantType)
-	v.Set("code", p.Code)
-	v.Set("redirect_uri", p.RedirectUri)
+	if p.Code != "" {
+		v.Set("code", p.Code)
+	}
+	if p.RedirectUri != "" {
+		v.Set("redirect_uri", p.RedirectUri)
+	}
+	if p.CodeVerifier != "" {
+		v.Set("code_verifier", p.CodeVerifier)
+	}
+	if p.RefreshToken != "" {
+		v.Set("refresh_token", p.RefreshToken)
+	}
 	return v.Encode()
 }
 
 type GetOAuth2TokenResponse struct {
-	AccessToken string `json:"access_token"`
-	TokenType   string `json:"token_type"`
+	AccessToken  string      `json:"access_token"`
+	TokenType    string      `json:"token_type"`
+	ExpiresIn    int         `json:"expires_in"`
+	RefreshToken string      `json:"refresh_token"`
+	Scope        OAuthScopes `json:"scope"`
+}
+
+// Expiry returns when the access token expires relative to issued, or the
+// zero time when the hub did not send expires_in.
+func (r *GetOAuth2TokenResponse) Expiry(issued time.Time) time.Time {
+	if r.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return issued.Add(time.Duration(r.ExpiresIn) * time.Second)
+}
+
+// OAuthScopes is a list of scopes encoded in JSON as the space separated
+// string used by OAuth 2.0. A JSON array is also accepted when decoding.
+type OAuthScopes []string
+
+func (s OAuthScopes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(strings.Join(s, " "))
+}
+
+func (s *OAuthScopes) UnmarshalJSON(data []byte) error {
+	var scopes []string
+	if err := json.Unmarshal(data, &scopes); err == nil {
+		*s = scopes
+		return nil
+	}
+
+	var scope string
+	if err := json.Unmarshal(data, &scope); err != nil {
+		return err
+	}
+	*s = strings.Fields(scope)
+	return nil
 }
 
 type ShutdownBody struct {
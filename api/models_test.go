@@ -0,0 +1,158 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestListUsersParams(t *testing.T) {
+	params := ListUsersParams{}
+	if encoded := params.Encode(); encoded != "" {
+		t.Errorf("Expected empty query for zero params, got %v", encoded)
+	}
+
+	params = ListUsersParams{State: ListUsersStateReady, NameFilter: "al", Limit: 10, IncludeStoppedServers: true}
+	expected := "include_stopped_servers=true&limit=10&name_filter=al&state=ready"
+	if encoded := params.Encode(); encoded != expected {
+		t.Errorf("Expected %v, got %v", expected, encoded)
+	}
+
+	for _, invalid := range []ListUsersParams{{State: "running"}, {Offset: -1}, {Limit: -5}} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: "http://127.0.0.1:1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.ListUsers(context.Background(), &ListUsersParams{State: "running"}); err == nil || Attempts(err) != 0 {
+		t.Errorf("Expected invalid state to be rejected before a request, got %v", err)
+	}
+}
+
+func TestListGroupsParams(t *testing.T) {
+	params := ListGroupsParams{NameFilter: "phys", Offset: 20}
+	expected := "name_filter=phys&offset=20"
+	if encoded := params.Encode(); encoded != expected {
+		t.Errorf("Expected %v, got %v", expected, encoded)
+	}
+}
+
+func TestDecodeTimestamps(t *testing.T) {
+	data := []byte(`{
+		"name": "username",
+		"pending": null,
+		"last_activity": "2024-03-01T12:30:00.123456Z",
+		"servers": {
+			"": {
+				"name": "",
+				"ready": false,
+				"pending": "spawn",
+				"started": "2024-03-01T12:29:58.000000Z",
+				"last_activity": null
+			}
+		}
+	}`)
+
+	var user JupyterHubUser
+	if err := json.Unmarshal(data, &user); err != nil {
+		t.Fatal(err)
+	}
+	expected := time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)
+	if user.LastActivity == nil || !user.LastActivity.Equal(expected) {
+		t.Errorf("Expected last activity %v, got %v", expected, user.LastActivity)
+	}
+	if user.Pending != PendingNone {
+		t.Errorf("Expected no pending action, got %v", user.Pending)
+	}
+
+	server := user.Servers[""]
+	if server.Pending != PendingSpawn || server.Started == nil || server.LastActivity != nil {
+		t.Errorf("Unexpected server %+v", server)
+	}
+
+	var token JupyterHubToken
+	if err := json.Unmarshal([]byte(`{"id": "a1", "created": "2024-01-01T00:00:00Z", "expires_at": null}`), &token); err != nil {
+		t.Fatal(err)
+	}
+	if token.Created.Year() != 2024 || token.ExpiresAt != nil {
+		t.Errorf("Unexpected token %+v", token)
+	}
+}
+
+func TestDecodeListResponses(t *testing.T) {
+	// Responses of JupyterHub 5 to GET /users/alice/tokens and GET /services.
+	var tokens ListTokenResponse
+	err := json.Unmarshal([]byte(`{
+		"api_tokens": [
+			{
+				"id": "a1",
+				"kind": "api_token",
+				"user": "alice",
+				"roles": [],
+				"scopes": ["access:servers!user=alice", "read:users!user=alice"],
+				"note": "ci",
+				"created": "2024-10-16T09:12:44.503281Z",
+				"expires_at": null,
+				"last_activity": "2024-10-16T09:13:02.114027Z",
+				"session_id": null,
+				"oauth_client": "JupyterHub"
+			},
+			{
+				"id": "o2",
+				"kind": "api_token",
+				"user": "alice",
+				"roles": [],
+				"scopes": ["inherit"],
+				"note": "Requested via api by user alice",
+				"created": "2024-10-16T09:14:00.000000Z",
+				"expires_at": "2024-10-17T09:14:00.000000Z",
+				"last_activity": null,
+				"session_id": "9f2c",
+				"oauth_client": "JupyterHub"
+			}
+		]
+	}`), &tokens)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tokens) != 2 || tokens[0].Note != "ci" || tokens[1].Id != "o2" || tokens[1].ExpiresAt == nil {
+		t.Errorf("Unexpected tokens %+v", tokens)
+	}
+
+	var services ListServicesResponse
+	err = json.Unmarshal([]byte(`{
+		"reports": {
+			"name": "reports",
+			"admin": false,
+			"roles": [],
+			"url": "http://127.0.0.1:10101",
+			"prefix": "/services/reports/",
+			"pid": 0,
+			"command": [],
+			"info": {},
+			"display": true
+		},
+		"billing": {
+			"name": "billing",
+			"admin": false,
+			"roles": [],
+			"url": "",
+			"prefix": "/services/billing/",
+			"pid": 0,
+			"command": [],
+			"info": {},
+			"display": true
+		}
+	}`), &services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "billing" || services[1].Url != "http://127.0.0.1:10101" {
+		t.Errorf("Unexpected services %+v", services)
+	}
+}
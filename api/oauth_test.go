@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseOAuthRequest(t *testing.T) {
+	client := &ClientConfig{}
+
+	request := httptest.NewRequest(http.MethodGet, "/oauth_callback?state=abc&code=thecode", nil)
+	if code, err := client.ParseOAuthRequest(request, "abc"); err != nil || code != "thecode" {
+		t.Errorf("Expected code thecode, got %q %v", code, err)
+	}
+
+	if _, err := client.ParseOAuthRequest(request, "xyz"); !errors.Is(err, ErrOAuthStateMismatch) {
+		t.Errorf("Expected ErrOAuthStateMismatch, got %v", err)
+	}
+
+	request = httptest.NewRequest(http.MethodGet, "/oauth_callback?state=abc&error=access_denied&error_description=User+denied", nil)
+	_, err := client.ParseOAuthRequest(request, "abc")
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "access_denied" || oauthErr.Description != "User denied" {
+		t.Errorf("Expected access_denied OAuthError, got %v", err)
+	}
+}
+
+func TestGetOAuth2Endpoint(t *testing.T) {
+	client := &ClientConfig{ClientId: "service-my-service", ApiURL: "http://hub/hub/api"}
+	pkce, err := NewPKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	endpoint, err := client.GetOAuth2Endpoint(&GetOAuth2EndpointParams{
+		State:         "abc",
+		RedirectUri:   "http://service/oauth_callback",
+		Scope:         []string{"read:users", "access:services"},
+		CodeChallenge: pkce.Challenge,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, expected := range []string{"scope=read%3Ausers+access%3Aservices", "code_challenge=" + pkce.Challenge, "code_challenge_method=S256"} {
+		if !strings.Contains(endpoint, expected) {
+			t.Errorf("Expected %v to contain %v", endpoint, expected)
+		}
+	}
+
+	for _, c := range []struct {
+		host     string
+		baseURL  string
+		expected string
+	}{
+		{"", "", "http://hub/hub/api/oauth2/authorize?"},
+		{"", "/prefix/", "/prefix/hub/api/oauth2/authorize?"},
+		{"https://hub.example.com", "/prefix/", "https://hub.example.com/prefix/hub/api/oauth2/authorize?"},
+	} {
+		client.Host, client.BaseURL = c.host, c.baseURL
+		endpoint, err := client.GetOAuth2Endpoint(&GetOAuth2EndpointParams{State: "abc", RedirectUri: "http://service/oauth_callback"})
+		if err != nil || !strings.HasPrefix(endpoint, c.expected) {
+			t.Errorf("Expected endpoint with Host %q and BaseURL %q to start with %v, got %v %v", c.host, c.baseURL, c.expected, endpoint, err)
+		}
+		if strings.Contains(endpoint, "code_challenge") {
+			t.Errorf("Expected no PKCE parameters without a challenge, got %v", endpoint)
+		}
+	}
+}
+
+func TestRefreshOAuth2Token(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "refresh_token" || r.PostForm.Get("refresh_token") != "refresh" || r.PostForm.Has("code") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error": "invalid_grant", "error_description": "Invalid refresh token"}`))
+			return
+		}
+		w.Write([]byte(`{"access_token": "new", "token_type": "Bearer", "expires_in": 60, "refresh_token": "refresh2", "scope": "read:users access:services"}`))
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "servicetoken", ApiURL: server.URL, ClientId: "service-my-service"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	token, err := client.RefreshOAuth2Token(ctx, "refresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.AccessToken != "new" || token.RefreshToken != "refresh2" || len(token.Scope) != 2 || token.Scope[1] != "access:services" {
+		t.Errorf("Unexpected token response %+v", token)
+	}
+	issued := time.Now()
+	if token.Expiry(issued) != issued.Add(time.Minute) {
+		t.Errorf("Expected token to expire a minute after issue")
+	}
+
+	_, err = client.RefreshOAuth2Token(ctx, "expired")
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) || oauthErr.Code != "invalid_grant" || !errors.Is(err, ErrBadRequest) {
+		t.Errorf("Expected invalid_grant OAuthError, got %v", err)
+	}
+}
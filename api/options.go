@@ -0,0 +1,93 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RequestOption overrides the behaviour of a single call without modifying
+// the ClientConfig it is made with.
+type RequestOption func(*requestOptions)
+
+type requestOptions struct {
+	timeout     time.Duration
+	header      http.Header
+	token       string
+	idempotent  bool
+	retryPolicy *RetryPolicy
+	operation   string
+	template    string
+}
+
+func newRequestOptions(c *ClientConfig, opts []RequestOption) *requestOptions {
+	options := &requestOptions{
+		header:      http.Header{},
+		token:       c.ApiToken,
+		retryPolicy: c.RetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(options)
+	}
+	return options
+}
+
+// operation names the client method making a request and its templated
+// path, such as "users/{name}", for Instrumentation. The caller's options are
+// applied after it.
+func operation(opts []RequestOption, name string, template string) []RequestOption {
+	return append([]RequestOption{func(o *requestOptions) {
+		o.operation = name
+		o.template = template
+	}}, opts...)
+}
+
+// requestHeader returns the headers sent with a request, with headers added
+// by WithHeader taking precedence.
+func (o *requestOptions) requestHeader(contentType string) http.Header {
+	header := http.Header{}
+	header.Set("Authorization", fmt.Sprintf("Bearer %s", o.token))
+	header.Set("Content-Type", contentType)
+	for key, values := range o.header {
+		header[key] = values
+	}
+	return header
+}
+
+// WithTimeout bounds the call, including all retries, to the given duration.
+func WithTimeout(timeout time.Duration) RequestOption {
+	return func(o *requestOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithHeader adds a header to the request, replacing any value set by the
+// client.
+func WithHeader(key string, value string) RequestOption {
+	return func(o *requestOptions) {
+		o.header.Set(key, value)
+	}
+}
+
+// WithToken authenticates the call with token instead of ClientConfig.ApiToken.
+func WithToken(token string) RequestOption {
+	return func(o *requestOptions) {
+		o.token = token
+	}
+}
+
+// WithIdempotent marks a POST or PATCH as safe to retry under the client's
+// RetryPolicy.
+func WithIdempotent() RequestOption {
+	return func(o *requestOptions) {
+		o.idempotent = true
+	}
+}
+
+// WithRetryPolicy replaces the client's RetryPolicy for the call. A nil
+// policy disables retries.
+func WithRetryPolicy(policy *RetryPolicy) RequestOption {
+	return func(o *requestOptions) {
+		o.retryPolicy = policy
+	}
+}
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRequestOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer othertoken" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if r.Header.Get("X-Request-Id") != "abc" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"name": "username"}`))
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.GetUser(ctx, "username"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("Expected client token to be rejected, got %v", err)
+	}
+	data, err := client.GetUser(ctx, "username", WithToken("othertoken"), WithHeader("X-Request-Id", "abc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != "username" {
+		t.Errorf("Expected user 'username', got %v", data.Name)
+	}
+}
+
+func TestRequestCancellation(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	err = client.StartUserServer(context.Background(), "username", nil, WithTimeout(50*time.Millisecond))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err = client.StartUserServer(ctx, "username", nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("Expected hung requests to be cancelled promptly")
+	}
+}
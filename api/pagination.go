@@ -0,0 +1,51 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+const paginationContentType = "application/jupyterhub-pagination+json"
+
+// paginated requests the pagination envelope supported by JupyterHub 2.5+
+// while still allowing the caller's options to override it.
+func paginated(opts []RequestOption) []RequestOption {
+	return append([]RequestOption{WithHeader("Accept", paginationContentType)}, opts...)
+}
+
+// decodePage decodes a paginated envelope into items, falling back to the
+// bare array or object returned by hubs that do not support pagination, in
+// which case the returned Pagination is nil.
+func decodePage(data []byte, items interface{}) (*Pagination, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		var envelope struct {
+			Items      json.RawMessage `json:"items"`
+			Pagination *Pagination     `json:"_pagination"`
+		}
+		if err := json.Unmarshal(trimmed, &envelope); err == nil && envelope.Pagination != nil {
+			return envelope.Pagination, json.Unmarshal(envelope.Items, items)
+		}
+	}
+	return nil, json.Unmarshal(trimmed, items)
+}
+
+// nextPage returns the offset and limit of the page following one that
+// returned count items.
+func nextPage(pagination *Pagination, offset int, limit int, count int) (int, int, bool) {
+	if count == 0 {
+		return 0, 0, false
+	}
+	if pagination != nil {
+		if pagination.Next == nil {
+			return 0, 0, false
+		}
+		return pagination.Next.Offset, pagination.Next.Limit, true
+	}
+	// Hubs without the pagination envelope either return every item or
+	// honour limit, in which case a full page may be followed by another.
+	if limit > 0 && count == limit {
+		return offset + count, limit, true
+	}
+	return 0, 0, false
+}
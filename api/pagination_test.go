@@ -0,0 +1,82 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+func newPaginatedUserServer(total int, supportsPagination bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offset, _ := strconv.Atoi(r.URL.Query().Get("offset"))
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil {
+			limit = 2
+		}
+
+		users := []JupyterHubUser{}
+		for i := offset; i < total && i < offset+limit; i++ {
+			users = append(users, JupyterHubUser{Name: fmt.Sprintf("user-%d", i)})
+		}
+
+		if !supportsPagination || r.Header.Get("Accept") != paginationContentType {
+			json.NewEncoder(w).Encode(users)
+			return
+		}
+
+		pagination := Pagination{Offset: offset, Limit: limit, Total: total}
+		if offset+limit < total {
+			pagination.Next = &PaginationNext{Offset: offset + limit, Limit: limit}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": users, "_pagination": pagination})
+	}))
+}
+
+func TestListAllUsers(t *testing.T) {
+	for _, supportsPagination := range []bool{true, false} {
+		server := newPaginatedUserServer(5, supportsPagination)
+		client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx := context.Background()
+
+		page, err := client.ListUsersPaginated(ctx, &ListUsersParams{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (page.Pagination != nil) != supportsPagination || len(page.Items) != 2 {
+			t.Errorf("Unexpected first page %+v with pagination support %v", page, supportsPagination)
+		}
+
+		users, err := client.ListAllUsers(ctx, &ListUsersParams{Limit: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(users) != 5 || users[4].Name != "user-4" {
+			t.Errorf("Expected 5 users ending with user-4, got %v", users)
+		}
+		server.Close()
+	}
+}
+
+func TestListAllUsersCancelled(t *testing.T) {
+	server := newPaginatedUserServer(5, true)
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.ListAllUsers(ctx, nil); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
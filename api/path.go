@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/url"
+	"strings"
+)
+
+const upperhex = "0123456789ABCDEF"
+
+// escapePathSegment mirrors jupyterhub.utils.url_escape_path, quoting every
+// byte except unreserved characters and "@". A "/" inside a username or
+// server name becomes %2F so that JupyterHub decodes it back into a single
+// path argument instead of routing it as an extra segment.
+func escapePathSegment(segment string) string {
+	var b strings.Builder
+	for i := 0; i < len(segment); i++ {
+		c := segment[i]
+		if shouldEscapePathByte(c) {
+			b.WriteByte('%')
+			b.WriteByte(upperhex[c>>4])
+			b.WriteByte(upperhex[c&15])
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+func shouldEscapePathByte(c byte) bool {
+	switch {
+	case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		return false
+	case c == '-' || c == '_' || c == '.' || c == '~' || c == '@':
+		return false
+	}
+	return true
+}
+
+// apiPath joins segments into a path relative to ClientConfig.ApiURL,
+// escaping each segment exactly once.
+func apiPath(segments ...string) string {
+	escaped := make([]string, len(segments))
+	for i, segment := range segments {
+		escaped[i] = escapePathSegment(segment)
+	}
+	return strings.Join(escaped, "/")
+}
+
+func apiPathWithQuery(query url.Values, segments ...string) string {
+	path := apiPath(segments...)
+	if len(query) == 0 {
+		return path
+	}
+	return path + "?" + query.Encode()
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+var awkwardNames = map[string]string{
+	"username":            "username",
+	"alice@example.com":   "alice@example.com",
+	"first last":          "first%20last",
+	"a/b":                 "a%2Fb",
+	"100%":                "100%25",
+	"ünïcode":             "%C3%BCn%C3%AFcode",
+	"q?x=1&y=2#frag":      "q%3Fx%3D1%26y%3D2%23frag",
+	"plus+sign;semicolon": "plus%2Bsign%3Bsemicolon",
+}
+
+func TestApiPath(t *testing.T) {
+	for name, expected := range awkwardNames {
+		if got := apiPath("users", name); got != "users/"+expected {
+			t.Errorf("Expected users/%v for %q, got %v", expected, name, got)
+		}
+	}
+
+	query := url.Values{}
+	query.Set("name_filter", "a b")
+	if got := apiPathWithQuery(query, "users"); got != "users?name_filter=a+b" {
+		t.Errorf("Expected users?name_filter=a+b, got %v", got)
+	}
+	if got := apiPathWithQuery(nil, "users"); got != "users" {
+		t.Errorf("Expected users without a query, got %v", got)
+	}
+}
+
+func TestAwkwardNamesReachHub(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// JupyterHub matches routes against the escaped path and unescapes
+		// each captured argument once.
+		segments := strings.Split(strings.TrimPrefix(r.URL.EscapedPath(), "/"), "/")
+		for _, segment := range segments {
+			unescaped, err := url.PathUnescape(segment)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			received = append(received, unescaped)
+		}
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	for name := range awkwardNames {
+		received = nil
+		if _, err := client.GetUserToken(ctx, name, "a/1"); err != nil {
+			t.Fatal(err)
+		}
+		if len(received) != 4 || received[1] != name || received[3] != "a/1" {
+			t.Errorf("Expected [users %v tokens a/1], got %q", name, received)
+		}
+	}
+}
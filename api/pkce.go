@@ -0,0 +1,31 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+
+	"github.com/costrouc/go-jupyterhub-api/utils"
+)
+
+const PKCEMethodS256 = "S256"
+
+// PKCE holds a code verifier and its S256 challenge (RFC 7636). Send the
+// challenge with GetOAuth2Endpoint and the verifier with GetOAuth2Token.
+type PKCE struct {
+	Verifier  string
+	Challenge string
+	Method    string
+}
+
+func NewPKCE() (*PKCE, error) {
+	verifier, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	return &PKCE{Verifier: verifier, Challenge: pkceChallenge(verifier), Method: PKCEMethodS256}, nil
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
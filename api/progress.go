@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// progressReconnectDelay is how long WatchServerProgress waits before
+// reopening a progress stream that ended without a ready or failed event.
+var progressReconnectDelay = time.Second
+
+// maxProgressLine is the longest line of a progress stream that is read,
+// allowing for events with long messages such as spawner logs.
+const maxProgressLine = 1 << 20
+
+func serverPath(username string, serverName string, segments ...string) string {
+	if serverName == "" {
+		return apiPath(append([]string{"users", username, "server"}, segments...)...)
+	}
+	return apiPath(append([]string{"users", username, "servers", serverName}, segments...)...)
+}
+
+// serverTemplate is serverPath with the user and server names left as
+// placeholders, for instrumentation.
+func serverTemplate(serverName string, segments ...string) string {
+	if serverName == "" {
+		return strings.Join(append([]string{"users/{name}/server"}, segments...), "/")
+	}
+	return strings.Join(append([]string{"users/{name}/servers/{server_name}"}, segments...), "/")
+}
+
+// WatchServerProgress streams the spawn progress of a user's default server,
+// or of a named server when serverName is set, calling fn for every event.
+// It reconnects if the stream ends early and returns nil once the server is
+// ready, an error wrapping ErrSpawnFailed if the spawn failed, or the error
+// returned by fn. Returning StopWatching from fn stops without an error.
+func (c *ClientConfig) WatchServerProgress(ctx context.Context, username string, serverName string, fn func(event ProgressEvent) error, opts ...RequestOption) error {
+	opts = append([]RequestOption{WithHeader("Accept", "text/event-stream")}, operation(opts, "WatchServerProgress", serverTemplate(serverName, "progress"))...)
+	path := serverPath(username, serverName, "progress")
+	for {
+		stream, err := c.requestStream(ctx, path, opts)
+		if err != nil {
+			return err
+		}
+		done, err := readProgressEvents(stream, fn)
+		stream.Close()
+		if errors.Is(err, StopWatching) {
+			return nil
+		}
+		if done || err != nil {
+			return err
+		}
+		if !sleepContext(ctx, progressReconnectDelay) {
+			return contextError(ctx)
+		}
+	}
+}
+
+// ServerProgressEvents is WatchServerProgress delivering events over a
+// channel. Both channels are closed once watching stops and at most one error
+// is sent.
+func (c *ClientConfig) ServerProgressEvents(ctx context.Context, username string, serverName string, opts ...RequestOption) (<-chan ProgressEvent, <-chan error) {
+	return progressEvents(ctx, func(fn func(event ProgressEvent) error) error {
+		return c.WatchServerProgress(ctx, username, serverName, fn, opts...)
+	})
+}
+
+// progressEvents adapts watch, a bound WatchServerProgress, to channels.
+func progressEvents(ctx context.Context, watch func(fn func(event ProgressEvent) error) error) (<-chan ProgressEvent, <-chan error) {
+	events := make(chan ProgressEvent)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		err := watch(func(event ProgressEvent) error {
+			select {
+			case events <- event:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+		if err != nil {
+			errs <- err
+		}
+	}()
+	return events, errs
+}
+
+// readProgressEvents parses a server-sent event stream, reporting whether a
+// terminal ready or failed event was seen. Read errors from a dropped
+// connection are not reported so that the caller reconnects.
+func readProgressEvents(stream io.Reader, fn func(event ProgressEvent) error) (bool, error) {
+	scanner := bufio.NewScanner(stream)
+	scanner.Buffer(nil, maxProgressLine)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		switch {
+		case len(line) == 0:
+			if data.Len() == 0 {
+				continue
+			}
+			var event ProgressEvent
+			if err := json.Unmarshal(data.Bytes(), &event); err != nil {
+				return false, err
+			}
+			data.Reset()
+			if err := fn(event); err != nil {
+				return false, err
+			}
+			if event.Failed {
+				return true, fmt.Errorf("%w: %s", ErrSpawnFailed, event.Message)
+			}
+			if event.Ready {
+				return true, nil
+			}
+		case bytes.HasPrefix(line, []byte("data:")):
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.Write(bytes.TrimPrefix(bytes.TrimPrefix(line, []byte("data:")), []byte(" ")))
+		}
+	}
+	if err := scanner.Err(); err != nil && !isDroppedConnection(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// isDroppedConnection reports whether err is from a connection that was
+// closed or cancelled while a response was being read.
+func isDroppedConnection(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &netErr)
+}
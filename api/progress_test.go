@@ -0,0 +1,98 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestWatchServerProgress(t *testing.T) {
+	progressReconnectDelay = time.Millisecond
+	var connections int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != "/users/alice@example.com/servers/lab/progress" || r.Header.Get("Accept") != "text/event-stream" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		if atomic.AddInt32(&connections, 1) == 1 {
+			fmt.Fprint(w, "data: {\"progress\": 0, \"message\": \"Server requested\"}\n\n")
+			fmt.Fprint(w, ":keepalive\n\n")
+			fmt.Fprint(w, "data: {\"progress\": 50, \"message\": \"Pulling image\"}\n\n")
+			return
+		}
+		fmt.Fprint(w, "data: {\"progress\": 100, \"ready\": true, \"message\": \"Ready\", \"url\": \"/user/alice@example.com/lab/\"}\n\n")
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	events, errs := client.ServerProgressEvents(ctx, "alice@example.com", "lab")
+	var received []ProgressEvent
+	for event := range events {
+		received = append(received, event)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 3 || received[1].Progress != 50 || !received[2].Ready || received[2].Url != "/user/alice@example.com/lab/" {
+		t.Errorf("Unexpected progress events %+v", received)
+	}
+	if connections != 2 {
+		t.Errorf("Expected a reconnect after the stream ended early, got %d connections", connections)
+	}
+}
+
+func TestWatchServerProgressFailed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {\"progress\": 100, \"failed\": true, \"message\": \"Spawn failed: timeout\"}\n\n")
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = client.WatchServerProgress(context.Background(), "username", "", func(event ProgressEvent) error {
+		return nil
+	})
+	if !errors.Is(err, ErrSpawnFailed) {
+		t.Errorf("Expected ErrSpawnFailed, got %v", err)
+	}
+}
+
+func TestReadProgressEventsErrors(t *testing.T) {
+	message := strings.Repeat("x", 100*1024)
+	var received []ProgressEvent
+	done, err := readProgressEvents(strings.NewReader("data: {\"ready\": true, \"message\": \""+message+"\"}\n\n"), func(event ProgressEvent) error {
+		received = append(received, event)
+		return nil
+	})
+	if err != nil || !done || len(received) != 1 || received[0].Message != message {
+		t.Errorf("Expected a long event to be read, got %v %v", done, err)
+	}
+
+	long := strings.NewReader("data: " + strings.Repeat("x", maxProgressLine) + "\n\n")
+	if _, err := readProgressEvents(long, func(event ProgressEvent) error { return nil }); !errors.Is(err, bufio.ErrTooLong) {
+		t.Errorf("Expected bufio.ErrTooLong, got %v", err)
+	}
+
+	dropped := io.MultiReader(strings.NewReader("data: {\"progress\": 50}\n\n"), iotest.ErrReader(io.ErrUnexpectedEOF))
+	if done, err := readProgressEvents(dropped, func(event ProgressEvent) error { return nil }); done || err != nil {
+		t.Errorf("Expected a dropped connection to be ignored, got %v %v", done, err)
+	}
+}
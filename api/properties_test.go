@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	var target, patch, expected interface{}
+	json.Unmarshal([]byte(`{"image": "base", "limits": {"cpu": 1, "mem": "2G"}, "tags": ["a"]}`), &target)
+	json.Unmarshal([]byte(`{"image": null, "limits": {"cpu": 2}, "tags": ["b"], "profile": "gpu"}`), &patch)
+	json.Unmarshal([]byte(`{"limits": {"cpu": 2, "mem": "2G"}, "tags": ["b"], "profile": "gpu"}`), &expected)
+
+	if merged := mergePatch(target, patch); !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Expected %v, got %v", expected, merged)
+	}
+	if target.(map[string]interface{})["image"] != "base" {
+		t.Errorf("Expected target to be left unmodified")
+	}
+}
+
+type spawnerProperties struct {
+	Image  string            `json:"image"`
+	Limits map[string]string `json:"limits"`
+}
+
+func TestGroupProperties(t *testing.T) {
+	properties := `{"image": "base", "limits": {"mem": "2G"}}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/groups/physics/properties":
+			var body json.RawMessage
+			json.NewDecoder(r.Body).Decode(&body)
+			properties = string(body)
+		case r.URL.Path == "/groups/physics":
+			w.Write([]byte(`{"name": "physics", "properties": ` + properties + `}`))
+		case r.URL.Path == "/users/alice":
+			w.Write([]byte(`{"name": "alice", "auth_state": {"access_token": "secret"}}`))
+		default:
+			w.Write([]byte(`{"name": "bob", "auth_state": null}`))
+		}
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.PatchGroupProperties(ctx, "physics", spawnerProperties{Image: "cuda", Limits: map[string]string{"cpu": "4"}}); err != nil {
+		t.Fatal(err)
+	}
+	result, err := GetGroupProperties[spawnerProperties](ctx, client, "physics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Image != "cuda" || result.Limits["cpu"] != "4" || result.Limits["mem"] != "2G" {
+		t.Errorf("Unexpected merged properties %+v", result)
+	}
+	for _, patch := range []interface{}{nil, []string{"cuda"}, "cuda"} {
+		if _, err := client.PatchGroupProperties(ctx, "physics", patch); err == nil {
+			t.Errorf("Expected patch %#v to be rejected", patch)
+		}
+	}
+	if result, err := GetGroupProperties[spawnerProperties](ctx, client, "physics"); err != nil || result.Image != "cuda" {
+		t.Errorf("Expected rejected patches to leave properties unchanged, got %+v %v", result, err)
+	}
+
+	authState, err := GetUserAuthState[map[string]string](ctx, client, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if (*authState)["access_token"] != "secret" {
+		t.Errorf("Unexpected auth state %v", *authState)
+	}
+	if _, err := GetUserAuthState[map[string]string](ctx, client, "bob"); !errors.Is(err, ErrAuthStateUnavailable) {
+		t.Errorf("Expected ErrAuthStateUnavailable, got %v", err)
+	}
+}
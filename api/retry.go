@@ -0,0 +1,188 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// RetryPolicy controls how Request retries transient failures. A nil policy
+// on ClientConfig performs exactly one attempt.
+type RetryPolicy struct {
+	MaxAttempts        int
+	InitialBackoff     time.Duration
+	MaxBackoff         time.Duration
+	Multiplier         float64
+	Jitter             float64
+	RetryStatusCodes   []int
+	RetryNonIdempotent bool
+}
+
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    4,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryStatusCodes: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+// RequestError wraps a transport level failure, such as a refused or reset
+// connection, together with the number of attempts made.
+type RequestError struct {
+	Method   string
+	Path     string
+	Attempts int
+	Err      error
+}
+
+// newRequestError builds a RequestError with the token of a token lookup
+// hidden from its path and from the URL reported by the transport.
+func newRequestError(method string, path string, attempts int, err error) *RequestError {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redact.Path(urlErr.URL)
+	}
+	return &RequestError{Method: method, Path: redact.Path(path), Attempts: attempts, Err: err}
+}
+
+func (e *RequestError) Error() string {
+	return e.Method + " " + e.Path + ": " + e.Err.Error()
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+// Attempts reports how many attempts were made before err was returned by
+// Request. It returns 0 if err did not come from Request.
+func Attempts(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Attempts
+	}
+	var requestErr *RequestError
+	if errors.As(err, &requestErr) {
+		return requestErr.Attempts
+	}
+	return 0
+}
+
+func (p *RetryPolicy) maxAttempts() int {
+	if p == nil || p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p *RetryPolicy) retryable(method string, idempotent bool, statusCode int, err error) bool {
+	if p == nil {
+		return false
+	}
+	if !idempotent && !p.RetryNonIdempotent && !isIdempotent(method) {
+		return false
+	}
+	if err != nil {
+		return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	for _, code := range p.RetryStatusCodes {
+		if code == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// wait sleeps before the next attempt, returning false if the request should
+// not be retried after all.
+func (p *RetryPolicy) wait(ctx context.Context, attempt int, resp *http.Response) bool {
+	delay, ok := p.backoff(attempt, resp)
+	return ok && sleepContext(ctx, delay)
+}
+
+// backoff is the delay before the next attempt. It reports false when the
+// hub asks to wait longer than MaxBackoff with Retry-After, in which case the
+// request is not retried.
+func (p *RetryPolicy) backoff(attempt int, resp *http.Response) (time.Duration, bool) {
+	if resp != nil {
+		if delay, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			return delay, p.MaxBackoff <= 0 || delay <= p.MaxBackoff
+		}
+	}
+
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	delay := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay += delay * p.Jitter * (2*rand.Float64() - 1)
+	}
+	return time.Duration(delay), true
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// sleepContext waits for delay unless ctx is done first or its deadline would
+// pass before the wait is over.
+func sleepContext(ctx context.Context, delay time.Duration) bool {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+		return false
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// contextError explains why sleepContext returned false.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return context.DeadlineExceeded
+}
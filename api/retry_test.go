@@ -0,0 +1,107 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+		if r.URL.Path == "/users/flaky" && n < 3 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/users/flaky" {
+			w.Write([]byte(`{"name": "flaky"}`))
+			return
+		}
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL, RetryPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	data, err := client.GetUser(ctx, "flaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Name != "flaky" || requests != 3 {
+		t.Errorf("Expected user 'flaky' after 3 requests, got %v after %d", data.Name, requests)
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	_, err = client.GetUser(ctx, "broken")
+	if !errors.Is(err, ErrServerError) || Attempts(err) != policy.MaxAttempts {
+		t.Errorf("Expected server error after %d attempts, got %v after %d", policy.MaxAttempts, err, Attempts(err))
+	}
+
+	atomic.StoreInt32(&requests, 0)
+	_, err = client.CreateUser(ctx, "broken")
+	if Attempts(err) != 1 || requests != 1 {
+		t.Errorf("Expected non-idempotent POST to be attempted once, got %d", requests)
+	}
+}
+
+func TestRetryRespectsDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL, RetryPolicy: DefaultRetryPolicy()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	_, err = client.GetInfo(ctx)
+	if !errors.Is(err, ErrTooManyRequests) || Attempts(err) != 1 {
+		t.Errorf("Expected a single attempt returning ErrTooManyRequests, got %v after %d", err, Attempts(err))
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected Retry-After beyond the deadline to fail fast")
+	}
+}
+
+func TestRetryAfterBeyondMaxBackoff(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	policy := DefaultRetryPolicy()
+	policy.MaxBackoff = time.Second
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL, RetryPolicy: policy})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err = client.GetInfo(context.Background())
+	if !errors.Is(err, ErrServerError) || requests != 1 {
+		t.Errorf("Expected a single attempt returning ErrServerError, got %v after %d", err, requests)
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("Expected Retry-After beyond MaxBackoff not to be waited for")
+	}
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/costrouc/go-jupyterhub-api/scope"
+)
+
+// ScopeSet parses and expands the scopes held by the identity.
+func (i *HubIdentity) ScopeSet() (scope.Set, error) {
+	return scope.ParseSet(i.Scopes)
+}
+
+// MissingScopes returns the required scopes that the identity does not hold.
+// A required scope with a filter is held when the identity holds it for that
+// resource, for example access:servers!user=alice holds
+// access:servers!server=alice/lab.
+func (i *HubIdentity) MissingScopes(required ...string) ([]string, error) {
+	held, err := i.ScopeSet()
+	if err != nil {
+		return nil, err
+	}
+
+	var missing []string
+	for _, raw := range required {
+		s, err := scope.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		if !held.Covers(s) {
+			missing = append(missing, raw)
+		}
+	}
+	return missing, nil
+}
+
+// RequireScopes returns a handler wrapper that answers 403 unless the caller
+// holds every one of scopes. It must be used inside HubAuth.Middleware or
+// HubOAuth.Middleware, which provide the caller's identity.
+func RequireScopes(scopes ...string) func(http.Handler) http.Handler {
+	return RequireScopesFunc(func(r *http.Request) []string {
+		return scopes
+	})
+}
+
+// RequireScopesFunc is RequireScopes with the scopes derived from each
+// request, so that filters can name the resource being accessed:
+//
+//	api.RequireScopesFunc(func(r *http.Request) []string {
+//		return []string{"read:users!user=" + r.URL.Query().Get("user")}
+//	})
+func RequireScopesFunc(fn func(r *http.Request) []string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			identity, ok := IdentityFromContext(r.Context())
+			if !ok {
+				writeError(w, http.StatusUnauthorized, "missing credentials")
+				return
+			}
+
+			missing, err := identity.MissingScopes(fn(r)...)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if len(missing) != 0 {
+				writeError(w, http.StatusForbidden, identity.Name+" is missing required scopes: "+strings.Join(missing, ", "))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRequireScopes(t *testing.T) {
+	handler := RequireScopesFunc(func(r *http.Request) []string {
+		return []string{"custom:reports:read", "access:servers!server=" + r.URL.Query().Get("server")}
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	identity := &HubIdentity{
+		Name:   "username",
+		Scopes: []string{"custom:reports:read", "access:servers!user=username"},
+	}
+	cases := []struct {
+		identity *HubIdentity
+		server   string
+		status   int
+	}{
+		{nil, "username/lab", http.StatusUnauthorized},
+		{identity, "username/lab", http.StatusNoContent},
+		{identity, "other/lab", http.StatusForbidden},
+		{&HubIdentity{Name: "other", Scopes: []string{"admin:servers", "access:servers"}}, "username/lab", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/reports?server="+c.server, nil)
+		if c.identity != nil {
+			r = r.WithContext(ContextWithIdentity(r.Context(), c.identity))
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != c.status {
+			t.Errorf("Expected status %d for %+v on %v, got %d", c.status, c.identity, c.server, w.Code)
+		}
+		if w.Code == http.StatusForbidden && !strings.Contains(w.Body.String(), "missing required scopes") {
+			t.Errorf("Expected message naming missing scopes, got %v", w.Body.String())
+		}
+	}
+}
+
+func TestHubAuthAllowedBroaderScope(t *testing.T) {
+	auth := &HubAuth{accessScopes: []string{"access:services!service=my-service"}}
+	if !auth.Allowed(&HubIdentity{Scopes: []string{"access:services"}}) {
+		t.Errorf("Expected unfiltered access:services to allow access")
+	}
+	if auth.Allowed(&HubIdentity{Scopes: []string{"access:services!service=other"}}) {
+		t.Errorf("Expected access to another service not to allow access")
+	}
+}
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// SpawnerOptions is implemented by the typed user options of the spawners
+// supported by StartUserServerWith. Options for other spawners can be passed
+// to StartUserServer directly.
+type SpawnerOptions interface {
+	spawnerOptions()
+}
+
+// KubeSpawnerOptions selects an entry of KubeSpawner's profile_list. Each
+// entry of ProfileOptions is sent as a top level user option named after the
+// profile option, which is how KubeSpawner reads choices made in its form.
+// Image and the resource fields are only honoured by deployments that apply
+// them from user_options, such as through a profile option or pre_spawn_hook.
+type KubeSpawnerOptions struct {
+	Profile        string
+	Image          string
+	CPULimit       float64
+	CPUGuarantee   float64
+	MemLimit       string
+	MemGuarantee   string
+	ProfileOptions map[string]string
+}
+
+var kubeSpawnerOptionKeys = map[string]bool{
+	"profile":       true,
+	"image":         true,
+	"cpu_limit":     true,
+	"cpu_guarantee": true,
+	"mem_limit":     true,
+	"mem_guarantee": true,
+}
+
+func (o KubeSpawnerOptions) MarshalJSON() ([]byte, error) {
+	options := map[string]interface{}{}
+	for key, value := range o.ProfileOptions {
+		if kubeSpawnerOptionKeys[key] {
+			return nil, fmt.Errorf("profile option %q conflicts with a KubeSpawnerOptions field", key)
+		}
+		options[key] = value
+	}
+	if o.Profile != "" {
+		options["profile"] = o.Profile
+	}
+	if o.Image != "" {
+		options["image"] = o.Image
+	}
+	if o.CPULimit != 0 {
+		options["cpu_limit"] = o.CPULimit
+	}
+	if o.CPUGuarantee != 0 {
+		options["cpu_guarantee"] = o.CPUGuarantee
+	}
+	if o.MemLimit != "" {
+		options["mem_limit"] = o.MemLimit
+	}
+	if o.MemGuarantee != "" {
+		options["mem_guarantee"] = o.MemGuarantee
+	}
+	return json.Marshal(options)
+}
+
+func (o *KubeSpawnerOptions) UnmarshalJSON(data []byte) error {
+	var options map[string]json.RawMessage
+	if err := json.Unmarshal(data, &options); err != nil {
+		return err
+	}
+
+	var known struct {
+		Profile      string  `json:"profile"`
+		Image        string  `json:"image"`
+		CPULimit     float64 `json:"cpu_limit"`
+		CPUGuarantee float64 `json:"cpu_guarantee"`
+		MemLimit     string  `json:"mem_limit"`
+		MemGuarantee string  `json:"mem_guarantee"`
+	}
+	if err := json.Unmarshal(data, &known); err != nil {
+		return err
+	}
+	*o = KubeSpawnerOptions{
+		Profile:      known.Profile,
+		Image:        known.Image,
+		CPULimit:     known.CPULimit,
+		CPUGuarantee: known.CPUGuarantee,
+		MemLimit:     known.MemLimit,
+		MemGuarantee: known.MemGuarantee,
+	}
+
+	for key, raw := range options {
+		if kubeSpawnerOptionKeys[key] {
+			continue
+		}
+		var value string
+		if err := json.Unmarshal(raw, &value); err != nil {
+			value = string(raw)
+		}
+		if o.ProfileOptions == nil {
+			o.ProfileOptions = map[string]string{}
+		}
+		o.ProfileOptions[key] = value
+	}
+	return nil
+}
+
+// DockerSpawnerOptions selects one of DockerSpawner's allowed_images.
+type DockerSpawnerOptions struct {
+	Image string `json:"image,omitempty"`
+}
+
+// BatchSpawnerOptions are substituted into BatchSpawner's batch script
+// template as {queue}, {runtime}, {nprocs} and {memory}.
+type BatchSpawnerOptions struct {
+	Queue   string `json:"queue,omitempty"`
+	Runtime string `json:"runtime,omitempty"`
+	Nprocs  int    `json:"nprocs,omitempty"`
+	Memory  string `json:"memory,omitempty"`
+}
+
+func (KubeSpawnerOptions) spawnerOptions()   {}
+func (DockerSpawnerOptions) spawnerOptions() {}
+func (BatchSpawnerOptions) spawnerOptions()  {}
+
+// StartUserServerWith starts the default server of username with the user
+// options of a supported spawner.
+func StartUserServerWith[T SpawnerOptions](ctx context.Context, c *ClientConfig, username string, options T, opts ...RequestOption) error {
+	return c.StartUserServer(ctx, username, options, opts...)
+}
+
+// StartUserNamedServerWith starts serverName with the user options of a
+// supported spawner.
+func StartUserNamedServerWith[T SpawnerOptions](ctx context.Context, c *ClientConfig, username string, serverName string, options T, opts ...RequestOption) error {
+	return c.StartUserNamedServer(ctx, username, serverName, options, opts...)
+}
+
+// DecodeUserOptions decodes the user_options a server was started with, as
+// returned to callers with the admin:server_state or read:servers scope.
+func DecodeUserOptions[T any](server *JupyterHubServer) (*T, error) {
+	return convert[T](server.UserOptions)
+}
+
+// DecodeServerState decodes the spawner state of a server, which the hub
+// only returns to callers with the admin:server_state scope.
+func DecodeServerState[T any](server *JupyterHubServer) (*T, error) {
+	return convert[T](server.State)
+}
+
+// convert re-encodes an untyped JSON value decoded by encoding/json into T.
+func convert[T any](value interface{}) (*T, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var result T
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKubeSpawnerOptions(t *testing.T) {
+	options := KubeSpawnerOptions{
+		Profile:        "gpu",
+		MemLimit:       "8G",
+		ProfileOptions: map[string]string{"image": "ignored"},
+	}
+	if _, err := json.Marshal(options); err == nil {
+		t.Errorf("Expected profile option conflicting with Image to be rejected")
+	}
+
+	options.ProfileOptions = map[string]string{"accelerator": "a100"}
+	data, err := json.Marshal(options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"accelerator":"a100","mem_limit":"8G","profile":"gpu"}`
+	if string(data) != expected {
+		t.Errorf("Expected %v, got %v", expected, string(data))
+	}
+
+	var server JupyterHubServer
+	if err := json.Unmarshal([]byte(`{"name": "", "user_options": `+expected+`, "state": {"pod_name": "jupyter-username"}}`), &server); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeUserOptions[KubeSpawnerOptions](&server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Profile != "gpu" || decoded.MemLimit != "8G" || decoded.ProfileOptions["accelerator"] != "a100" {
+		t.Errorf("Unexpected decoded options %+v", decoded)
+	}
+
+	state, err := DecodeServerState[struct {
+		PodName string `json:"pod_name"`
+	}](&server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.PodName != "jupyter-username" {
+		t.Errorf("Expected pod name jupyter-username, got %v", state.PodName)
+	}
+}
+
+func TestStartUserServerWith(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received = string(body)
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = StartUserNamedServerWith(context.Background(), client, "username", "batch", BatchSpawnerOptions{Queue: "debug", Nprocs: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if received != `{"queue":"debug","nprocs":4}` {
+		t.Errorf("Unexpected user options %v", received)
+	}
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Middleware wraps the transport used for every hub request. Middleware
+// listed first in ClientConfig.Middleware is the outermost wrapper.
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// defaultTransport is shared by every client that does not provide its own
+// so that connections to the hub are pooled across clients.
+var defaultTransport http.RoundTripper = newDefaultTransport()
+
+func newDefaultTransport() *http.Transport {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConns = 100
+	transport.MaxIdleConnsPerHost = 100
+	transport.IdleConnTimeout = 90 * time.Second
+	transport.ResponseHeaderTimeout = 2 * time.Minute
+	return transport
+}
+
+func (c *ClientConfig) buildHTTPClient() *http.Client {
+	var client http.Client
+	if c.HTTPClient != nil {
+		client = *c.HTTPClient
+	}
+
+	transport := client.Transport
+	if c.Transport != nil {
+		transport = c.Transport
+	}
+	if transport == nil {
+		transport = defaultTransport
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		transport = c.Middleware[i](transport)
+	}
+	client.Transport = transport
+	return &client
+}
+
+func (c *ClientConfig) client() *http.Client {
+	if c.httpClient != nil {
+		return c.httpClient
+	}
+	return c.buildHTTPClient()
+}
+
+// cancelReadCloser releases the context of a streamed request once its body
+// is closed.
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (r *cancelReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	r.cancel()
+	return err
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+var userHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(`{"name": "username", "admin": true}`))
+})
+
+func newUserServer() *httptest.Server {
+	return httptest.NewServer(userHandler)
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	server := newUserServer()
+	defer server.Close()
+
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(next http.RoundTripper) http.RoundTripper {
+			return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+				order = append(order, name)
+				return next.RoundTrip(r)
+			})
+		}
+	}
+
+	client, err := CreateClient(&ClientConfig{
+		ApiToken:   "usertoken",
+		ApiURL:     server.URL,
+		Transport:  server.Client().Transport,
+		Middleware: []Middleware{middleware("outer"), middleware("inner")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUser(context.Background(), "username"); err != nil {
+		t.Fatal(err)
+	}
+	if len(order) != 2 || order[0] != "outer" || order[1] != "inner" {
+		t.Errorf("Expected middleware order [outer inner], got %v", order)
+	}
+}
+
+func benchmarkGetUser(b *testing.B, transport http.RoundTripper, parallel bool) {
+	var conns atomic.Int64
+	server := httptest.NewUnstartedServer(userHandler)
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	server.Start()
+	defer server.Close()
+	defer func() {
+		b.ReportMetric(float64(conns.Load()), "conns")
+	}()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL, Transport: transport})
+	if err != nil {
+		b.Fatal(err)
+	}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	if !parallel {
+		for i := 0; i < b.N; i++ {
+			if _, err := client.GetUser(ctx, "username"); err != nil {
+				b.Fatal(err)
+			}
+		}
+		return
+	}
+	// At least 8 concurrent requests, more than the two idle connections
+	// per host that http.DefaultTransport keeps.
+	b.SetParallelism(8)
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := client.GetUser(ctx, "username"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+// BenchmarkGetUser compares the shared pooled transport with
+// http.DefaultTransport, which the previous &http.Client{} per request used,
+// reporting the connections opened to the hub as conns.
+func BenchmarkGetUser(b *testing.B) {
+	transports := []struct {
+		name      string
+		transport http.RoundTripper
+	}{
+		{"DefaultTransport", http.DefaultTransport},
+		{"PooledTransport", nil},
+	}
+	for _, t := range transports {
+		b.Run(t.name+"/Sequential", func(b *testing.B) {
+			benchmarkGetUser(b, t.transport, false)
+		})
+		b.Run(t.name+"/Parallel", func(b *testing.B) {
+			benchmarkGetUser(b, t.transport, true)
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateUserBody(t *testing.T) {
+	cases := map[string]*UpdateUserBody{
+		`{"name":"renamed"}`:                  (&UpdateUserBody{}).SetName("renamed"),
+		`{"admin":false}`:                     (&UpdateUserBody{}).SetAdmin(false),
+		`{"name":"renamed","admin":true}`:     (&UpdateUserBody{}).SetName("renamed").SetAdmin(true),
+		`{"auth_state":{"access_token":"x"}}`: (&UpdateUserBody{}).SetAuthState(map[string]string{"access_token": "x"}),
+		`{"auth_state":null}`:                 (&UpdateUserBody{}).SetAuthState(nil),
+		`{}`:                                  {},
+	}
+	for expected, body := range cases {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != expected {
+			t.Errorf("Expected %v, got %v", expected, string(data))
+		}
+	}
+}
+
+func TestModifyUser(t *testing.T) {
+	var patches []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			body, _ := io.ReadAll(r.Body)
+			patches = append(patches, string(body))
+		}
+		w.Write([]byte(`{"name": "username", "admin": true, "auth_state": {"team": "a"}}`))
+	}))
+	defer server.Close()
+
+	client, err := CreateClient(&ClientConfig{ApiToken: "usertoken", ApiURL: server.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	_, err = client.ModifyUser(ctx, "username", func(user *JupyterHubUser) error {
+		user.Name = "renamed"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ModifyUser(ctx, "username", func(user *JupyterHubUser) error {
+		user.AuthState.(map[string]interface{})["team"] = "b"
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ModifyUser(ctx, "username", func(user *JupyterHubUser) error {
+		user.Admin = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.ModifyUser(ctx, "username", func(user *JupyterHubUser) error {
+		user.AuthState = nil
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{`{"name":"renamed"}`, `{"auth_state":{"team":"b"}}`, `{"auth_state":null}`}
+	if !reflect.DeepEqual(patches, expected) {
+		t.Errorf("Expected patches %v, got %v", expected, patches)
+	}
+}
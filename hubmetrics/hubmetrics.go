@@ -0,0 +1,281 @@
+// Package hubmetrics records metrics about the requests made by api clients
+// and serves them in the Prometheus text exposition format, using only the
+// standard library.
+//
+//	metrics := hubmetrics.New(nil)
+//	client, err := api.CreateClient(&api.ClientConfig{Instrumentation: metrics})
+//	http.Handle("/metrics", metrics)
+//
+// Requests are labelled with the client operation, the HTTP method and the
+// templated path, such as "users/{name}", so that the number of series does
+// not grow with the number of users.
+package hubmetrics
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+)
+
+// DefaultBuckets are the latency histogram buckets in seconds, the same as
+// the Prometheus client libraries use by default.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Config configures the metrics. A nil Config uses the defaults.
+type Config struct {
+	// Namespace prefixes every metric name, "jupyterhub_client" by default.
+	Namespace string
+	// Buckets are the upper bounds of the latency histogram in seconds,
+	// DefaultBuckets by default.
+	Buckets []float64
+}
+
+// Metrics is an api.Instrumentation that counts requests and their latency,
+// and an http.Handler that serves the counts.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	mu        sync.Mutex
+	inFlight  map[endpoint]int64
+	requests  map[result]uint64
+	retries   map[endpoint]uint64
+	durations map[endpoint]*histogram
+}
+
+type endpoint struct {
+	operation string
+	method    string
+	path      string
+}
+
+type result struct {
+	endpoint
+	code string
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+var (
+	_ api.Instrumentation = (*Metrics)(nil)
+	_ http.Handler        = (*Metrics)(nil)
+)
+
+// New creates empty metrics. A nil config uses the defaults.
+func New(config *Config) *Metrics {
+	m := &Metrics{
+		namespace: "jupyterhub_client",
+		buckets:   DefaultBuckets,
+		inFlight:  map[endpoint]int64{},
+		requests:  map[result]uint64{},
+		retries:   map[endpoint]uint64{},
+		durations: map[endpoint]*histogram{},
+	}
+	if config != nil && config.Namespace != "" {
+		m.namespace = config.Namespace
+	}
+	if config != nil && len(config.Buckets) != 0 {
+		m.buckets = nil
+		for _, bound := range config.Buckets {
+			if !math.IsInf(bound, 1) {
+				m.buckets = append(m.buckets, bound)
+			}
+		}
+		sort.Float64s(m.buckets)
+	}
+	return m
+}
+
+func endpointOf(info api.RequestInfo) endpoint {
+	return endpoint{operation: info.Operation, method: info.Method, path: info.Path}
+}
+
+func (m *Metrics) Start(ctx context.Context, info api.RequestInfo) context.Context {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[endpointOf(info)]++
+	return ctx
+}
+
+func (m *Metrics) Finish(ctx context.Context, info api.RequestInfo, outcome api.RequestResult) {
+	e := endpointOf(info)
+	code := "error"
+	if outcome.StatusCode != 0 {
+		code = strconv.Itoa(outcome.StatusCode)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight[e]--
+	m.requests[result{endpoint: e, code: code}]++
+	if outcome.Attempts > 1 {
+		m.retries[e] += uint64(outcome.Attempts - 1)
+	}
+	h, ok := m.durations[e]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		m.durations[e] = h
+	}
+	seconds := outcome.Duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &countingWriter{w: bufio.NewWriter(w)}
+
+	name := m.namespace + "_requests_total"
+	out.header(name, "counter", "Requests made to the JupyterHub API by operation, method, templated path and status code.")
+	results := make([]result, 0, len(m.requests))
+	for r := range m.requests {
+		results = append(results, r)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].endpoint != results[j].endpoint {
+			return less(results[i].endpoint, results[j].endpoint)
+		}
+		return results[i].code < results[j].code
+	})
+	for _, r := range results {
+		out.sample(name, labels(r.endpoint, "code", r.code), float64(m.requests[r]))
+	}
+
+	name = m.namespace + "_request_duration_seconds"
+	out.header(name, "histogram", "Latency of requests to the JupyterHub API, including retries.")
+	for _, e := range sortedEndpoints(m.durations) {
+		h := m.durations[e]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += h.counts[i]
+			out.sample(name+"_bucket", labels(e, "le", formatFloat(bound)), float64(cumulative))
+		}
+		out.sample(name+"_bucket", labels(e, "le", "+Inf"), float64(h.count))
+		out.sample(name+"_sum", labels(e), h.sum)
+		out.sample(name+"_count", labels(e), float64(h.count))
+	}
+
+	name = m.namespace + "_request_retries_total"
+	out.header(name, "counter", "Retries of requests to the JupyterHub API.")
+	for _, e := range sortedEndpoints(m.retries) {
+		out.sample(name, labels(e), float64(m.retries[e]))
+	}
+
+	name = m.namespace + "_requests_in_flight"
+	out.header(name, "gauge", "Requests to the JupyterHub API that have not completed.")
+	for _, e := range sortedEndpoints(m.inFlight) {
+		out.sample(name, labels(e), float64(m.inFlight[e]))
+	}
+
+	if out.err == nil {
+		out.err = out.w.Flush()
+	}
+	return out.n, out.err
+}
+
+func less(a endpoint, b endpoint) bool {
+	if a.operation != b.operation {
+		return a.operation < b.operation
+	}
+	if a.path != b.path {
+		return a.path < b.path
+	}
+	return a.method < b.method
+}
+
+func sortedEndpoints[V any](m map[endpoint]V) []endpoint {
+	endpoints := make([]endpoint, 0, len(m))
+	for e := range m {
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return less(endpoints[i], endpoints[j])
+	})
+	return endpoints
+}
+
+// labels formats the labels of e followed by extra name and value pairs.
+func labels(e endpoint, extra ...string) string {
+	pairs := append([]string{"operation", e.operation, "method", e.method, "path", e.path}, extra...)
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func formatFloat(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// countingWriter writes lines of the exposition format, keeping the first
+// error and the number of bytes written.
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) printf(format string, args ...interface{}) {
+	if c.err != nil {
+		return
+	}
+	n, err := fmt.Fprintf(c.w, format, args...)
+	c.n += int64(n)
+	c.err = err
+}
+
+func (c *countingWriter) header(name string, kind string, help string) {
+	c.printf("# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (c *countingWriter) sample(name string, labels string, value float64) {
+	c.printf("%s%s %s\n", name, labels, formatFloat(value))
+}
@@ -0,0 +1,73 @@
+package hubmetrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+	"github.com/costrouc/go-jupyterhub-api/hubtest"
+)
+
+func TestMetrics(t *testing.T) {
+	hub := hubtest.NewServer(nil)
+	defer hub.Close()
+	hub.AddUser("alice", true)
+	hub.Fail(http.MethodGet, "users/alice", http.StatusServiceUnavailable, 1)
+
+	metrics := New(&Config{Buckets: []float64{10, 0.5}})
+	policy := api.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client, err := api.CreateClient(&api.ClientConfig{
+		ApiToken:        hub.AddToken("alice"),
+		ApiURL:          hub.ApiURL(),
+		RetryPolicy:     policy,
+		Instrumentation: metrics,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if _, err := client.GetUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	client.GetUser(ctx, "bob")
+	client.GetGroup(ctx, "missing")
+
+	recorder := httptest.NewRecorder()
+	metrics.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if contentType := recorder.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Errorf("Unexpected content type %s", contentType)
+	}
+	output := recorder.Body.String()
+	for _, expected := range []string{
+		"# TYPE jupyterhub_client_requests_total counter\n",
+		`jupyterhub_client_requests_total{operation="GetUser",method="GET",path="users/{name}",code="200"} 1` + "\n",
+		`jupyterhub_client_requests_total{operation="GetUser",method="GET",path="users/{name}",code="404"} 1` + "\n",
+		`jupyterhub_client_requests_total{operation="GetGroup",method="GET",path="groups/{name}",code="404"} 1` + "\n",
+		"# TYPE jupyterhub_client_request_duration_seconds histogram\n",
+		`jupyterhub_client_request_duration_seconds_bucket{operation="GetUser",method="GET",path="users/{name}",le="0.5"} 2` + "\n",
+		`jupyterhub_client_request_duration_seconds_bucket{operation="GetUser",method="GET",path="users/{name}",le="10"} 2` + "\n",
+		`jupyterhub_client_request_duration_seconds_bucket{operation="GetUser",method="GET",path="users/{name}",le="+Inf"} 2` + "\n",
+		`jupyterhub_client_request_duration_seconds_count{operation="GetUser",method="GET",path="users/{name}"} 2` + "\n",
+		`jupyterhub_client_request_retries_total{operation="GetUser",method="GET",path="users/{name}"} 1` + "\n",
+		`jupyterhub_client_requests_in_flight{operation="GetUser",method="GET",path="users/{name}"} 0` + "\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected metrics to contain %q, got\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "bob") || strings.Contains(output, "alice") {
+		t.Errorf("Expected paths to be templated, got\n%s", output)
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if escaped := escapeLabel("a\"b\\c\nd"); escaped != `a\"b\\c\nd` {
+		t.Errorf("Unexpected escaped label %s", escaped)
+	}
+}
@@ -0,0 +1,1053 @@
+package hubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+	"github.com/costrouc/go-jupyterhub-api/scope"
+)
+
+const (
+	paginationContentType = "application/jupyterhub-pagination+json"
+	defaultPageLimit      = 50
+	maxPageLimit          = 200
+)
+
+// adminScopes are the scopes of JupyterHub's admin role.
+var adminScopes = []string{
+	"admin-ui", "admin:users", "admin:servers", "tokens", "admin:groups",
+	"admin:services", "read:hub", "proxy", "shutdown",
+	"access:services", "access:servers", "read:roles", "read:metrics", "shares",
+}
+
+// selfScopes are the scopes of JupyterHub's user role, which expands the
+// self scope to the user's own resources.
+func selfScopes(username string) []string {
+	filter := "!user=" + username
+	return []string{
+		"read:users" + filter, "users:activity" + filter, "servers" + filter,
+		"access:servers" + filter, "tokens" + filter, "read:users:shares" + filter,
+	}
+}
+
+// caller is the owner of the token that authenticated a request.
+type caller struct {
+	token  *token
+	scopes scope.Set
+}
+
+func (c *caller) require(w http.ResponseWriter, action string, resource scope.Resource) bool {
+	if c.scopes.Allows(action, resource) {
+		return true
+	}
+	writeError(w, http.StatusForbidden, fmt.Sprintf("Action is not authorized with current scopes; requires any of [%s]", action))
+	return false
+}
+
+func (h *Hub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	hooks := append([]Hook(nil), h.hooks...)
+	h.mu.Unlock()
+	for _, hook := range hooks {
+		if hook(w, r) {
+			return
+		}
+	}
+
+	path := r.URL.EscapedPath()
+	if path != "/hub/api" && !strings.HasPrefix(path, "/hub/api/") {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	segments, err := splitPath(strings.TrimPrefix(path, "/hub/api"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if len(segments) == 0 {
+		writeJSON(w, http.StatusOK, api.VersionResponse{Version: Version})
+		return
+	}
+	if segments[0] == "oauth2" {
+		h.serveOAuth(w, r, segments[1:])
+		return
+	}
+
+	c, ok := h.authenticate(r)
+	if !ok {
+		writeError(w, http.StatusForbidden, "Missing or invalid credentials.")
+		return
+	}
+
+	if r.Method == http.MethodGet && len(segments) >= 4 && segments[0] == "users" && segments[len(segments)-1] == "progress" {
+		switch {
+		case len(segments) == 4 && segments[2] == "server":
+			h.serveProgress(w, r, c, segments[1], "")
+			return
+		case len(segments) == 5 && segments[2] == "servers":
+			h.serveProgress(w, r, c, segments[1], segments[3])
+			return
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch segments[0] {
+	case "info":
+		h.serveInfo(w, r, c, segments[1:])
+	case "user":
+		h.serveCurrentUser(w, r, c, segments[1:])
+	case "users":
+		h.serveUsers(w, r, c, segments[1:])
+	case "groups":
+		h.serveGroups(w, r, c, segments[1:])
+	case "services":
+		h.serveServices(w, r, c, segments[1:])
+	case "proxy":
+		h.serveProxy(w, r, c, segments[1:])
+	case "authorizations":
+		h.serveAuthorizations(w, r, c, segments[1:])
+	case "shutdown":
+		h.serveShutdown(w, r, c, segments[1:])
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (h *Hub) authenticate(r *http.Request) (*caller, bool) {
+	value := api.TokenFromRequest(r)
+	if value == "" {
+		return nil, false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t, ok := h.tokens[value]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if t.expiresAt != nil && now.After(*t.expiresAt) {
+		delete(h.tokens, value)
+		return nil, false
+	}
+	t.lastActivity = &now
+	return &caller{token: t, scopes: h.tokenScopes(t)}, true
+}
+
+// tokenScopes returns the expanded scopes held by t, which inherits the
+// permissions of its owner when it was issued without scopes.
+func (h *Hub) tokenScopes(t *token) scope.Set {
+	raw := t.scopes
+	if raw == nil {
+		switch {
+		case t.service != "":
+			if s, ok := h.services[t.service]; ok {
+				raw = s.scopes
+			}
+		case h.users[t.user] != nil && h.users[t.user].admin:
+			raw = adminScopes
+		default:
+			raw = selfScopes(t.user)
+		}
+	}
+	set, err := scope.ParseSet(raw)
+	if err != nil {
+		return scope.Set{}
+	}
+	return set
+}
+
+func (h *Hub) serveInfo(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) != 0 || r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if !c.require(w, "read:hub", scope.Resource{}) {
+		return
+	}
+	writeJSON(w, http.StatusOK, api.InfoResponse{
+		Version:       Version,
+		Python:        "3.12.0",
+		SysExecutable: "/usr/bin/python3",
+		Authenticator: api.AuthenticatorClass{Class: "jupyterhub.auth.DummyAuthenticator", Version: Version},
+		Spawner:       api.SpawnerClass{Class: "hubtest.FakeSpawner", Version: Version},
+	})
+}
+
+func (h *Hub) serveCurrentUser(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) != 0 || r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	writeJSON(w, http.StatusOK, h.identityModel(c.token))
+}
+
+type userIdentityModel struct {
+	api.JupyterHubUser
+	Kind string `json:"kind"`
+}
+
+// identityModel is the model of the owner of t returned by /user.
+func (h *Hub) identityModel(t *token) interface{} {
+	set := h.tokenScopes(t)
+	if t.service != "" {
+		return map[string]interface{}{
+			"kind":   api.IdentityKindService,
+			"name":   t.service,
+			"admin":  false,
+			"roles":  []string{},
+			"scopes": set.Strings(),
+		}
+	}
+
+	model := userIdentityModel{Kind: api.IdentityKindUser}
+	if u, ok := h.users[t.user]; ok {
+		model.JupyterHubUser = h.userModel(&caller{token: t, scopes: set}, u, false)
+	}
+	model.Scopes = set.Strings()
+	return model
+}
+
+func (h *Hub) serveUsers(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) == 0 {
+		switch r.Method {
+		case http.MethodGet:
+			h.listUsers(w, r, c)
+		case http.MethodPost:
+			h.createUsers(w, r, c)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+		return
+	}
+
+	name := segments[0]
+	switch {
+	case len(segments) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.getUser(w, r, c, name)
+		case http.MethodPost:
+			h.createUser(w, r, c, name)
+		case http.MethodPatch:
+			h.updateUser(w, r, c, name)
+		case http.MethodDelete:
+			h.deleteUser(w, r, c, name)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	case len(segments) == 2 && segments[1] == "activity" && r.Method == http.MethodPost:
+		h.userActivity(w, r, c, name)
+	case len(segments) == 2 && segments[1] == "server":
+		h.serveServer(w, r, c, name, "")
+	case len(segments) == 3 && segments[1] == "servers":
+		h.serveServer(w, r, c, name, segments[2])
+	case len(segments) == 2 && segments[1] == "tokens":
+		switch r.Method {
+		case http.MethodGet:
+			h.listTokens(w, r, c, name)
+		case http.MethodPost:
+			h.createToken(w, r, c, name)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	case len(segments) == 3 && segments[1] == "tokens":
+		switch r.Method {
+		case http.MethodGet:
+			h.getToken(w, r, c, name, segments[2])
+		case http.MethodDelete:
+			h.deleteToken(w, r, c, name, segments[2])
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (h *Hub) userResource(name string) scope.Resource {
+	return scope.User(name, h.userGroups(name)...)
+}
+
+func (h *Hub) userGroups(name string) []string {
+	groups := []string{}
+	for _, g := range h.sortedGroups() {
+		for _, member := range g.users {
+			if member == name {
+				groups = append(groups, g.name)
+				break
+			}
+		}
+	}
+	return groups
+}
+
+func (h *Hub) sortedUsers() []*user {
+	users := make([]*user, 0, len(h.users))
+	for _, u := range h.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].id < users[j].id })
+	return users
+}
+
+func (h *Hub) userModel(c *caller, u *user, includeStopped bool) api.JupyterHubUser {
+	roles := []string{"user"}
+	if u.admin {
+		roles = []string{"admin"}
+	}
+	groups := h.userGroups(u.name)
+	model := api.JupyterHubUser{
+		Name:         u.name,
+		Admin:        u.admin,
+		Roles:        roles,
+		Groups:       groups,
+		LastActivity: u.lastActivity,
+	}
+	if s, ok := u.servers[""]; ok && s.active() {
+		model.Server = serverURL(u.name, "")
+		model.Pending = s.pending
+	}
+	if c.scopes.Allows("read:servers", scope.User(u.name, groups...)) {
+		model.Servers = map[string]api.JupyterHubServer{}
+		for name, s := range u.servers {
+			if s.active() || includeStopped {
+				model.Servers[name] = h.serverModel(c, u, s)
+			}
+		}
+	}
+	if c.scopes.Allows("admin:auth_state", scope.User(u.name, groups...)) {
+		model.AuthState = u.authState
+	}
+	return model
+}
+
+func (h *Hub) listUsers(w http.ResponseWriter, r *http.Request, c *caller) {
+	if !c.scopes.Has("list:users") {
+		writeError(w, http.StatusForbidden, "Action is not authorized with current scopes; requires any of [list:users]")
+		return
+	}
+	query := r.URL.Query()
+	state := query.Get("state")
+	switch state {
+	case "", api.ListUsersStateActive, api.ListUsersStateReady, api.ListUsersStateInactive:
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Unrecognized state: %s", state))
+		return
+	}
+	offset, limit, err := pageParams(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	_, includeStopped := query["include_stopped_servers"]
+
+	var models []api.JupyterHubUser
+	for _, u := range h.sortedUsers() {
+		if !c.scopes.Allows("list:users", h.userResource(u.name)) || !strings.HasPrefix(u.name, query.Get("name_filter")) {
+			continue
+		}
+		active, ready := false, false
+		for _, s := range u.servers {
+			active = active || s.active()
+			ready = ready || s.ready
+		}
+		if (state == api.ListUsersStateActive && !active) || (state == api.ListUsersStateReady && !ready) || (state == api.ListUsersStateInactive && active) {
+			continue
+		}
+		models = append(models, h.userModel(c, u, includeStopped))
+	}
+
+	total := len(models)
+	page := []api.JupyterHubUser{}
+	if offset < total {
+		page = append(page, models[offset:min(offset+limit, total)]...)
+	}
+	h.writePage(w, r, page, offset, limit, total)
+}
+
+func (h *Hub) createUsers(w http.ResponseWriter, r *http.Request, c *caller) {
+	var body api.CreateUsersBody
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(body.Usernames) == 0 {
+		writeError(w, http.StatusBadRequest, "Must specify at least one user to create")
+		return
+	}
+	for _, name := range body.Usernames {
+		if !c.require(w, "admin:users", h.userResource(name)) {
+			return
+		}
+	}
+
+	created := []api.JupyterHubUser{}
+	for _, name := range body.Usernames {
+		if _, ok := h.users[name]; ok || name == "" {
+			continue
+		}
+		created = append(created, h.userModel(c, h.addUser(name, body.Admin), false))
+	}
+	if len(created) == 0 {
+		writeError(w, http.StatusConflict, fmt.Sprintf("All %d users already exist", len(body.Usernames)))
+		return
+	}
+	writeJSON(w, http.StatusCreated, created)
+}
+
+func (h *Hub) getUser(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "read:users", h.userResource(name)) {
+		return
+	}
+	u, ok := h.users[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	_, includeStopped := r.URL.Query()["include_stopped_servers"]
+	writeJSON(w, http.StatusOK, h.userModel(c, u, includeStopped))
+}
+
+func (h *Hub) createUser(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "admin:users", h.userResource(name)) {
+		return
+	}
+	var body struct {
+		Admin bool `json:"admin"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if _, ok := h.users[name]; ok {
+		writeError(w, http.StatusConflict, fmt.Sprintf("User %s already exists", name))
+		return
+	}
+	writeJSON(w, http.StatusCreated, h.userModel(c, h.addUser(name, body.Admin), false))
+}
+
+func (h *Hub) updateUser(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "admin:users", h.userResource(name)) {
+		return
+	}
+	u, ok := h.users[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	var body struct {
+		Name      *string         `json:"name"`
+		Admin     *bool           `json:"admin"`
+		AuthState json.RawMessage `json:"auth_state"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.AuthState != nil && !c.require(w, "admin:auth_state", h.userResource(name)) {
+		return
+	}
+	if body.Name != nil && *body.Name != name {
+		if _, exists := h.users[*body.Name]; exists {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("User %s already exists, username must be unique", *body.Name))
+			return
+		}
+		h.renameUser(u, *body.Name)
+	}
+	if body.Admin != nil {
+		u.admin = *body.Admin
+	}
+	if body.AuthState != nil {
+		var authState interface{}
+		json.Unmarshal(body.AuthState, &authState)
+		u.authState = authState
+	}
+	writeJSON(w, http.StatusOK, h.userModel(c, u, false))
+}
+
+func (h *Hub) renameUser(u *user, name string) {
+	for _, s := range u.servers {
+		if s.ready {
+			delete(h.routes, serverURL(u.name, s.name))
+		}
+	}
+	for _, g := range h.groups {
+		for i, member := range g.users {
+			if member == u.name {
+				g.users[i] = name
+			}
+		}
+	}
+	for _, t := range h.tokens {
+		if t.user == u.name {
+			t.user = name
+		}
+	}
+	delete(h.users, u.name)
+	u.name = name
+	h.users[name] = u
+	for _, s := range u.servers {
+		if s.ready {
+			h.addRoute(u, s)
+		}
+	}
+}
+
+func (h *Hub) deleteUser(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "delete:users", h.userResource(name)) {
+		return
+	}
+	u, ok := h.users[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	for _, s := range u.servers {
+		h.finishStop(u, s, true)
+	}
+	for _, g := range h.groups {
+		g.users = removeString(g.users, name)
+	}
+	for value, t := range h.tokens {
+		if t.user == name {
+			delete(h.tokens, value)
+		}
+	}
+	delete(h.users, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Hub) userActivity(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "users:activity", h.userResource(name)) {
+		return
+	}
+	u, ok := h.users[name]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	var body api.UserActivityBody
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for serverName := range body.Servers {
+		if _, ok := u.servers[serverName]; !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("No such server '%s' for user %s", serverName, name))
+			return
+		}
+	}
+
+	if body.LastActivity != nil && (u.lastActivity == nil || body.LastActivity.After(*u.lastActivity)) {
+		u.lastActivity = body.LastActivity
+	}
+	for serverName, activity := range body.Servers {
+		lastActivity := activity.LastActivity
+		s := u.servers[serverName]
+		if s.lastActivity == nil || lastActivity.After(*s.lastActivity) {
+			s.lastActivity = &lastActivity
+		}
+		if u.lastActivity == nil || lastActivity.After(*u.lastActivity) {
+			u.lastActivity = &lastActivity
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func tokenModel(t *token) api.JupyterHubToken {
+	scopes := t.scopes
+	if scopes == nil {
+		scopes = []string{"inherit"}
+	}
+	return api.JupyterHubToken{
+		Id:           t.id,
+		User:         t.user,
+		Service:      t.service,
+		Roles:        []string{},
+		Scopes:       scopes,
+		Note:         t.note,
+		Created:      t.created,
+		ExpiresAt:    t.expiresAt,
+		LastActivity: t.lastActivity,
+	}
+}
+
+func (h *Hub) userTokens(name string) []*token {
+	var tokens []*token
+	for _, t := range h.tokens {
+		if t.user == name {
+			tokens = append(tokens, t)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool { return tokens[i].created.Before(tokens[j].created) })
+	return tokens
+}
+
+func (h *Hub) listTokens(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "read:tokens", h.userResource(name)) {
+		return
+	}
+	if _, ok := h.users[name]; !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	models := []api.JupyterHubToken{}
+	for _, t := range h.userTokens(name) {
+		models = append(models, tokenModel(t))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"api_tokens": models})
+}
+
+func (h *Hub) createToken(w http.ResponseWriter, r *http.Request, c *caller, name string) {
+	if !c.require(w, "tokens", h.userResource(name)) {
+		return
+	}
+	if _, ok := h.users[name]; !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+name)
+		return
+	}
+	var body api.CreateUserTokenBody
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	for _, raw := range body.Scopes {
+		if _, err := scope.Parse(raw); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	t := &token{user: name, note: body.Note}
+	if t.note == "" {
+		t.note = "Requested via api"
+	}
+	if len(body.Scopes) != 0 {
+		t.scopes = body.Scopes
+	}
+	h.issueToken(t)
+	if body.ExpiresIn > 0 {
+		expiresAt := t.created.Add(time.Duration(body.ExpiresIn) * time.Second)
+		t.expiresAt = &expiresAt
+	}
+	model := tokenModel(t)
+	model.Token = t.value
+	writeJSON(w, http.StatusCreated, model)
+}
+
+func (h *Hub) findToken(name string, id string) *token {
+	for _, t := range h.userTokens(name) {
+		if t.id == id {
+			return t
+		}
+	}
+	return nil
+}
+
+func (h *Hub) getToken(w http.ResponseWriter, r *http.Request, c *caller, name string, id string) {
+	if !c.require(w, "read:tokens", h.userResource(name)) {
+		return
+	}
+	t := h.findToken(name, id)
+	if t == nil {
+		writeError(w, http.StatusNotFound, "No such token: "+id)
+		return
+	}
+	writeJSON(w, http.StatusOK, tokenModel(t))
+}
+
+func (h *Hub) deleteToken(w http.ResponseWriter, r *http.Request, c *caller, name string, id string) {
+	if !c.require(w, "tokens", h.userResource(name)) {
+		return
+	}
+	t := h.findToken(name, id)
+	if t == nil {
+		writeError(w, http.StatusNotFound, "No such token: "+id)
+		return
+	}
+	delete(h.tokens, t.value)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Hub) sortedGroups() []*group {
+	groups := make([]*group, 0, len(h.groups))
+	for _, g := range h.groups {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].id < groups[j].id })
+	return groups
+}
+
+func groupModel(g *group) api.JupyterHubGroup {
+	return api.JupyterHubGroup{
+		Name:       g.name,
+		Users:      append([]string{}, g.users...),
+		Properties: g.properties,
+		Roles:      []string{},
+	}
+}
+
+func (h *Hub) serveGroups(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) == 0 {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+		h.listGroups(w, r, c)
+		return
+	}
+
+	name := segments[0]
+	resource := scope.Group(name)
+	g, exists := h.groups[name]
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodPost:
+		if !c.require(w, "admin:groups", resource) {
+			return
+		}
+		if exists {
+			writeError(w, http.StatusConflict, fmt.Sprintf("Group %s already exists", name))
+			return
+		}
+		var body api.AddGroupUsersBody
+		if err := decodeBody(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !h.checkUsers(w, body.Users) {
+			return
+		}
+		h.nextId++
+		g = &group{id: h.nextId, name: name, users: append([]string{}, body.Users...), properties: map[string]interface{}{}}
+		h.groups[name] = g
+		writeJSON(w, http.StatusCreated, groupModel(g))
+		return
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		if !c.require(w, "read:groups", resource) {
+			return
+		}
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		if !c.require(w, "delete:groups", resource) {
+			return
+		}
+	case len(segments) == 2 && segments[1] == "users" && (r.Method == http.MethodPost || r.Method == http.MethodDelete):
+		if !c.require(w, "groups", resource) {
+			return
+		}
+	case len(segments) == 2 && segments[1] == "properties" && r.Method == http.MethodPut:
+		if !c.require(w, "groups", resource) {
+			return
+		}
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "No such group: "+name)
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, groupModel(g))
+	case len(segments) == 1:
+		delete(h.groups, name)
+		w.WriteHeader(http.StatusNoContent)
+	case segments[1] == "users":
+		var body api.AddGroupUsersBody
+		if err := decodeBody(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if !h.checkUsers(w, body.Users) {
+			return
+		}
+		for _, member := range body.Users {
+			g.users = removeString(g.users, member)
+			if r.Method == http.MethodPost {
+				g.users = append(g.users, member)
+			}
+		}
+		writeJSON(w, http.StatusOK, groupModel(g))
+	default:
+		var properties interface{}
+		if err := decodeBody(r, &properties); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if _, ok := properties.(map[string]interface{}); !ok {
+			writeError(w, http.StatusBadRequest, "properties must be a dict")
+			return
+		}
+		g.properties = properties
+		writeJSON(w, http.StatusOK, groupModel(g))
+	}
+}
+
+func (h *Hub) checkUsers(w http.ResponseWriter, users []string) bool {
+	for _, name := range users {
+		if _, ok := h.users[name]; !ok {
+			writeError(w, http.StatusBadRequest, "No such user: "+name)
+			return false
+		}
+	}
+	return true
+}
+
+func (h *Hub) listGroups(w http.ResponseWriter, r *http.Request, c *caller) {
+	if !c.scopes.Has("list:groups") {
+		writeError(w, http.StatusForbidden, "Action is not authorized with current scopes; requires any of [list:groups]")
+		return
+	}
+	query := r.URL.Query()
+	offset, limit, err := pageParams(query)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	var models []api.JupyterHubGroup
+	for _, g := range h.sortedGroups() {
+		if c.scopes.Allows("list:groups", scope.Group(g.name)) && strings.HasPrefix(g.name, query.Get("name_filter")) {
+			models = append(models, groupModel(g))
+		}
+	}
+
+	total := len(models)
+	page := []api.JupyterHubGroup{}
+	if offset < total {
+		page = append(page, models[offset:min(offset+limit, total)]...)
+	}
+	h.writePage(w, r, page, offset, limit, total)
+}
+
+func serviceModel(s *service) api.JupyterHubService {
+	return api.JupyterHubService{
+		Name:    s.name,
+		Roles:   []string{},
+		Prefix:  "/services/" + url.PathEscape(s.name) + "/",
+		Command: []string{},
+		Info:    map[string]interface{}{},
+	}
+}
+
+func (h *Hub) serveServices(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if r.Method != http.MethodGet || len(segments) > 1 {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+
+	if len(segments) == 0 {
+		if !c.scopes.Has("list:services") {
+			writeError(w, http.StatusForbidden, "Action is not authorized with current scopes; requires any of [list:services]")
+			return
+		}
+		models := map[string]api.JupyterHubService{}
+		for name, s := range h.services {
+			if c.scopes.Allows("list:services", scope.Service(name)) {
+				models[name] = serviceModel(s)
+			}
+		}
+		writeJSON(w, http.StatusOK, models)
+		return
+	}
+
+	if !c.require(w, "read:services", scope.Service(segments[0])) {
+		return
+	}
+	s, ok := h.services[segments[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such service: "+segments[0])
+		return
+	}
+	writeJSON(w, http.StatusOK, serviceModel(s))
+}
+
+func (h *Hub) serveProxy(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) != 0 {
+		writeError(w, http.StatusNotFound, "Not Found")
+		return
+	}
+	if !c.require(w, "proxy", scope.Resource{}) {
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		offset, limit, err := pageParams(r.URL.Query())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		specs := make([]string, 0, len(h.routes))
+		for spec := range h.routes {
+			specs = append(specs, spec)
+		}
+		sort.Strings(specs)
+		page := map[string]api.JupyterHubProxyRoute{}
+		if offset < len(specs) {
+			for _, spec := range specs[offset:min(offset+limit, len(specs))] {
+				page[spec] = h.routes[spec]
+			}
+		}
+		h.writePage(w, r, page, offset, limit, len(specs))
+	case http.MethodPost:
+		w.WriteHeader(http.StatusOK)
+	case http.MethodPatch:
+		var body api.NotifyNewProxyBody
+		if err := decodeBody(r, &body); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (h *Hub) serveAuthorizations(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	switch {
+	case len(segments) == 1 && segments[0] == "token" && r.Method == http.MethodPost:
+		if c.token.user == "" {
+			writeError(w, http.StatusBadRequest, "Only users can request tokens")
+			return
+		}
+		t := h.issueToken(&token{user: c.token.user, scopes: c.token.scopes, note: "Requested via deprecated api"})
+		model := tokenModel(t)
+		model.Token = t.value
+		writeJSON(w, http.StatusOK, model)
+	case len(segments) == 2 && segments[0] == "token" && r.Method == http.MethodGet:
+		t, ok := h.tokens[segments[1]]
+		if !ok || (t.expiresAt != nil && time.Now().After(*t.expiresAt)) {
+			writeError(w, http.StatusNotFound, "Not Found")
+			return
+		}
+		writeJSON(w, http.StatusOK, h.identityModel(t))
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+func (h *Hub) serveShutdown(w http.ResponseWriter, r *http.Request, c *caller, segments []string) {
+	if len(segments) != 0 || r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+		return
+	}
+	if !c.require(w, "shutdown", scope.Resource{}) {
+		return
+	}
+	var body api.ShutdownBody
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	h.shutdown = true
+	writeJSON(w, http.StatusAccepted, map[string]interface{}{})
+}
+
+// pageParams parses offset and limit like JupyterHub, which applies a default
+// and maximum limit whether or not pagination was requested.
+func pageParams(query url.Values) (int, int, error) {
+	offset, limit := 0, defaultPageLimit
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("Invalid argument type, offset must be a non-negative integer")
+		}
+		offset = parsed
+	}
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			return 0, 0, fmt.Errorf("Invalid argument type, limit must be a non-negative integer")
+		}
+		if parsed > 0 {
+			limit = min(parsed, maxPageLimit)
+		}
+	}
+	return offset, limit, nil
+}
+
+// writePage writes items, wrapped in the pagination envelope when the client
+// asked for it with the Accept header.
+func (h *Hub) writePage(w http.ResponseWriter, r *http.Request, items interface{}, offset int, limit int, total int) {
+	if !strings.Contains(r.Header.Get("Accept"), paginationContentType) {
+		writeJSON(w, http.StatusOK, items)
+		return
+	}
+
+	pagination := api.Pagination{Offset: offset, Limit: limit, Total: total}
+	if offset+limit < total {
+		query := r.URL.Query()
+		query.Set("offset", strconv.Itoa(offset+limit))
+		query.Set("limit", strconv.Itoa(limit))
+		pagination.Next = &api.PaginationNext{
+			Offset: offset + limit,
+			Limit:  limit,
+			Url:    h.URL + r.URL.EscapedPath() + "?" + query.Encode(),
+		}
+	}
+	w.Header().Set("Content-Type", paginationContentType)
+	writeJSON(w, http.StatusOK, map[string]interface{}{"items": items, "_pagination": pagination})
+}
+
+func splitPath(path string) ([]string, error) {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil, nil
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			return nil, err
+		}
+		segments[i] = unescaped
+	}
+	return segments, nil
+}
+
+func decodeBody(r *http.Request, v interface{}) error {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// writeError writes JupyterHub's JSON error model.
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]interface{}{"status": status, "message": message})
+}
+
+func removeString(values []string, value string) []string {
+	result := values[:0]
+	for _, v := range values {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,288 @@
+// Package hubtest provides an in-memory JupyterHub REST API backed by
+// httptest, so that code built on the api package can be tested without a
+// running hub.
+//
+//	hub := hubtest.NewServer(nil)
+//	defer hub.Close()
+//	hub.AddUser("alice", false)
+//	client, err := hub.Client(hub.AddToken("alice"))
+//
+// The fake hub implements users, default and named servers with simulated
+// spawn and stop delays and progress events, tokens, groups and their
+// properties, services, the proxy table, the OAuth provider and shutdown.
+// Requests are authenticated with bearer tokens and authorized with the
+// scopes the token holds.
+package hubtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+)
+
+const Version = "5.2.1"
+
+// Config configures the simulated behaviour of a Hub.
+type Config struct {
+	// SpawnDelay is how long servers take to become ready after being
+	// started. Servers start immediately when zero.
+	SpawnDelay time.Duration
+	// StopDelay is how long servers take to stop. Servers stop immediately
+	// when zero.
+	StopDelay time.Duration
+	// ProgressInterval is the time between progress events while a server
+	// is spawning, a fifth of SpawnDelay by default.
+	ProgressInterval time.Duration
+	// OAuthTokenExpiresIn is the lifetime of access tokens issued by the
+	// OAuth provider. They do not expire when zero.
+	OAuthTokenExpiresIn time.Duration
+}
+
+// Hook is called for every request before the hub handles it. Returning
+// true means the hook has written the response itself.
+type Hook func(w http.ResponseWriter, r *http.Request) bool
+
+// Hub is a fake JupyterHub. Its API is served at URL + "/hub/api".
+type Hub struct {
+	URL string
+
+	server *httptest.Server
+	config Config
+
+	mu            sync.Mutex
+	nextId        int
+	users         map[string]*user
+	groups        map[string]*group
+	services      map[string]*service
+	tokens        map[string]*token
+	refreshTokens map[string]*token
+	codes         map[string]*oauthCode
+	routes        map[string]api.JupyterHubProxyRoute
+	spawnFailures map[serverKey]string
+	hooks         []Hook
+	loggedIn      string
+	shutdown      bool
+}
+
+type user struct {
+	id           int
+	name         string
+	admin        bool
+	authState    interface{}
+	created      time.Time
+	lastActivity *time.Time
+	servers      map[string]*server
+}
+
+type group struct {
+	id         int
+	name       string
+	users      []string
+	properties interface{}
+}
+
+type service struct {
+	name        string
+	scopes      []string
+	redirectURI string
+}
+
+type token struct {
+	id           string
+	value        string
+	user         string
+	service      string
+	oauthClient  string
+	scopes       []string
+	note         string
+	created      time.Time
+	expiresAt    *time.Time
+	lastActivity *time.Time
+}
+
+// NewServer starts a fake hub. A nil config uses the defaults.
+func NewServer(config *Config) *Hub {
+	h := &Hub{
+		users:         map[string]*user{},
+		groups:        map[string]*group{},
+		services:      map[string]*service{},
+		tokens:        map[string]*token{},
+		refreshTokens: map[string]*token{},
+		codes:         map[string]*oauthCode{},
+		routes:        map[string]api.JupyterHubProxyRoute{},
+		spawnFailures: map[serverKey]string{},
+	}
+	if config != nil {
+		h.config = *config
+	}
+	h.server = httptest.NewServer(h)
+	h.URL = h.server.URL
+	h.routes["/"] = api.JupyterHubProxyRoute{RouteSpec: "/", Target: h.URL, Data: map[string]interface{}{"hub": true}}
+	return h
+}
+
+// Close stops pending spawns and stops and shuts down the server.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	for _, u := range h.users {
+		for _, s := range u.servers {
+			s.cancel()
+		}
+	}
+	h.mu.Unlock()
+	h.server.Close()
+}
+
+// ApiURL is the URL of the hub's REST API.
+func (h *Hub) ApiURL() string {
+	return h.URL + "/hub/api"
+}
+
+// Client creates an api client for the hub that authenticates with token.
+func (h *Hub) Client(token string) (*api.ClientConfig, error) {
+	return api.CreateClient(&api.ClientConfig{
+		ApiToken: token,
+		ApiURL:   h.ApiURL(),
+		Host:     h.URL,
+		BaseURL:  "/",
+	})
+}
+
+// AddUser creates a user, or updates the admin status of an existing one.
+func (h *Hub) AddUser(name string, admin bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if u, ok := h.users[name]; ok {
+		u.admin = admin
+		return
+	}
+	h.addUser(name, admin)
+}
+
+// AddToken issues an API token for an existing user. Without scopes the
+// token inherits the user's permissions: every scope for admins and scopes
+// filtered to the user's own resources otherwise.
+func (h *Hub) AddToken(username string, scopes ...string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	t := &token{user: username, note: "hubtest"}
+	if len(scopes) != 0 {
+		t.scopes = scopes
+	}
+	return h.issueToken(t).value
+}
+
+// AddService registers a service holding scopes, along with its OAuth client
+// "service-<name>", and returns the service's API token, which is also its
+// OAuth client secret. An empty redirectURI accepts any redirect.
+func (h *Hub) AddService(name string, redirectURI string, scopes ...string) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.services[name] = &service{name: name, scopes: scopes, redirectURI: redirectURI}
+	return h.issueToken(&token{service: name, scopes: scopes, note: "service " + name}).value
+}
+
+// AddGroup creates a group, or replaces the members of an existing one.
+func (h *Hub) AddGroup(name string, users ...string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if g, ok := h.groups[name]; ok {
+		g.users = append([]string(nil), users...)
+		return
+	}
+	h.nextId++
+	h.groups[name] = &group{id: h.nextId, name: name, users: append([]string(nil), users...), properties: map[string]interface{}{}}
+}
+
+// LoginAs sets the user that the OAuth authorize endpoint grants access
+// for, as if they had logged in to the hub in their browser. Authorization
+// requests are denied while no user is logged in.
+func (h *Hub) LoginAs(username string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.loggedIn = username
+}
+
+// ShutdownRequested reports whether the shutdown endpoint has been called.
+func (h *Hub) ShutdownRequested() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.shutdown
+}
+
+// AddHook adds a hook that is called, in order, before every request.
+func (h *Hub) AddHook(hook Hook) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.hooks = append(h.hooks, hook)
+}
+
+// Fail answers the next times requests matching method and path with status
+// and a JupyterHub error body, or every matching request when times is not
+// positive. The path is relative to the API, such as "users/alice/server",
+// and an empty method matches every method.
+func (h *Hub) Fail(method string, path string, status int, times int) {
+	var mu sync.Mutex
+	remaining := times
+	h.AddHook(func(w http.ResponseWriter, r *http.Request) bool {
+		if method != "" && r.Method != method {
+			return false
+		}
+		if strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/hub/api"), "/") != strings.Trim(path, "/") {
+			return false
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		if times > 0 {
+			if remaining == 0 {
+				return false
+			}
+			remaining--
+		}
+		writeError(w, status, http.StatusText(status))
+		return true
+	})
+}
+
+// FailSpawn makes the next spawn of the user's server fail with message.
+func (h *Hub) FailSpawn(username string, serverName string, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.spawnFailures[serverKey{username, serverName}] = message
+}
+
+func (h *Hub) addUser(name string, admin bool) *user {
+	h.nextId++
+	u := &user{id: h.nextId, name: name, admin: admin, created: time.Now(), servers: map[string]*server{}}
+	h.users[name] = u
+	return u
+}
+
+func (h *Hub) issueToken(t *token) *token {
+	h.nextId++
+	prefix := "a"
+	if t.oauthClient != "" {
+		prefix = "o"
+	}
+	t.id = prefix + strconv.Itoa(h.nextId)
+	t.value = randomHex()
+	t.created = time.Now()
+	h.tokens[t.value] = t
+	return t
+}
+
+func randomHex() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,503 @@
+package hubtest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+)
+
+func newAdminClient(t *testing.T, hub *Hub) *api.ClientConfig {
+	t.Helper()
+	hub.AddUser("admin", true)
+	client, err := hub.Client(hub.AddToken("admin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestUsers(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	ctx := context.Background()
+
+	version, err := client.GetVersion(ctx)
+	if err != nil || version.Version != Version {
+		t.Fatalf("Unexpected version %+v %v", version, err)
+	}
+	if _, err := client.GetInfo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	current, err := client.GetCurrentUser(ctx)
+	if err != nil || current.Name != "admin" || !current.Admin {
+		t.Fatalf("Unexpected current user %+v %v", current, err)
+	}
+
+	if _, err := client.CreateUser(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateUser(ctx, "alice"); !errors.Is(err, api.ErrConflict) {
+		t.Errorf("Expected ErrConflict creating an existing user, got %v", err)
+	}
+	var usernames []string
+	for i := 0; i < 60; i++ {
+		usernames = append(usernames, "user"+string(rune('a'+i/26))+string(rune('a'+i%26)))
+	}
+	if created, err := client.CreateUsers(ctx, &api.CreateUsersBody{Usernames: usernames}); err != nil || len(*created) != 60 {
+		t.Fatalf("Expected 60 users to be created, got %v", err)
+	}
+
+	page, err := client.ListUsersPaginated(ctx, &api.ListUsersParams{Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Items) != 10 || page.Pagination.Total != 62 || page.Pagination.Next == nil {
+		t.Errorf("Unexpected first page %+v", page.Pagination)
+	}
+	all, err := client.ListAllUsers(ctx, &api.ListUsersParams{Limit: 25})
+	if err != nil || len(all) != 62 {
+		t.Fatalf("Expected 62 users, got %d %v", len(all), err)
+	}
+	filtered, err := client.ListUsers(ctx, &api.ListUsersParams{NameFilter: "al"})
+	if err != nil || len(*filtered) != 1 {
+		t.Fatalf("Expected name filter to match alice, got %v", err)
+	}
+
+	if _, err := client.UpdateUser(ctx, "alice", new(api.UpdateUserBody).SetAuthState(map[string]string{"access_token": "secret"})); err != nil {
+		t.Fatal(err)
+	}
+	authState, err := api.GetUserAuthState[map[string]string](ctx, client, "alice")
+	if err != nil || (*authState)["access_token"] != "secret" {
+		t.Errorf("Unexpected auth state %v %v", authState, err)
+	}
+	if _, err := client.UpdateUser(ctx, "alice", new(api.UpdateUserBody).SetAuthState(nil)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.GetUserAuthState[map[string]string](ctx, client, "alice"); !errors.Is(err, api.ErrAuthStateUnavailable) {
+		t.Errorf("Expected auth state to be cleared, got %v", err)
+	}
+
+	renamed, err := client.ModifyUser(ctx, "alice", func(user *api.JupyterHubUser) error {
+		user.Name = "alicia"
+		user.Admin = true
+		return nil
+	})
+	if err != nil || renamed.Name != "alicia" || !renamed.Admin {
+		t.Fatalf("Unexpected renamed user %+v %v", renamed, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	if err := client.NotifyUserActivity(ctx, "alicia", &api.UserActivityBody{LastActivity: &now}); err != nil {
+		t.Fatal(err)
+	}
+	user, err := client.GetUser(ctx, "alicia")
+	if err != nil || user.LastActivity == nil || !user.LastActivity.Equal(now) {
+		t.Errorf("Expected last activity %v, got %+v %v", now, user, err)
+	}
+
+	if err := client.DeleteUser(ctx, "alicia"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.GetUser(ctx, "alicia"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted user, got %v", err)
+	}
+}
+
+func TestScopes(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	hub.AddUser("alice", false)
+	hub.AddUser("bob", false)
+	hub.AddUser("carol", false)
+	hub.AddGroup("physics", "bob")
+	ctx := context.Background()
+
+	alice, err := hub.Client(hub.AddToken("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := alice.GetUser(ctx, "alice"); err != nil {
+		t.Errorf("Expected alice to read herself, got %v", err)
+	}
+	if _, err := alice.GetUser(ctx, "bob"); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden reading another user, got %v", err)
+	}
+	if _, err := alice.ListUsers(ctx, &api.ListUsersParams{}); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden listing users, got %v", err)
+	}
+
+	physics, err := hub.Client(hub.AddToken("alice", "list:users!group=physics", "read:users!group=physics"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	users, err := physics.ListUsers(ctx, &api.ListUsersParams{})
+	if err != nil || len(*users) != 1 || (*users)[0].Name != "bob" {
+		t.Errorf("Expected only bob to be listed, got %+v %v", users, err)
+	}
+	if _, err := physics.GetUser(ctx, "carol"); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden reading a user outside the group, got %v", err)
+	}
+
+	invalid, _ := hub.Client("invalid")
+	if _, err := invalid.GetCurrentUser(ctx); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for an invalid token, got %v", err)
+	}
+}
+
+func TestServers(t *testing.T) {
+	hub := NewServer(&Config{SpawnDelay: 50 * time.Millisecond, StopDelay: 20 * time.Millisecond})
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	hub.AddUser("alice", false)
+	ctx := context.Background()
+
+	result, err := client.StartServer(ctx, "alice", "", map[string]string{"profile": "small"})
+	if err != nil || result != api.StartResultPending {
+		t.Fatalf("Expected pending start, got %v %v", result, err)
+	}
+	var events []api.ProgressEvent
+	err = client.WatchServerProgress(ctx, "alice", "", func(event api.ProgressEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) < 2 || !events[len(events)-1].Ready || events[0].Ready {
+		t.Errorf("Expected progress events ending in ready, got %+v", events)
+	}
+	if err := client.StartUserServer(ctx, "alice", nil); !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest starting a running server, got %v", err)
+	}
+
+	user, err := client.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	options, err := api.DecodeUserOptions[map[string]string](ptr(user.Servers[""]))
+	if err != nil || (*options)["profile"] != "small" {
+		t.Errorf("Expected user options to be stored, got %v %v", options, err)
+	}
+	routes, err := client.AllProxyRoutes(ctx, nil)
+	if _, ok := routes["/user/alice/"]; err != nil || !ok {
+		t.Errorf("Expected a proxy route for alice's server, got %v %v", routes, err)
+	}
+
+	stopped, err := client.StopAndWait(ctx, "alice", "", &api.WaitOptions{PollInterval: 10 * time.Millisecond})
+	if err != nil || stopped != api.StopResultStopped {
+		t.Errorf("Expected server to stop, got %v %v", stopped, err)
+	}
+	if result, err := client.StopServer(ctx, "alice", "", false); err != nil || result != api.StopResultAlreadyStopped {
+		t.Errorf("Expected already stopped, got %v %v", result, err)
+	}
+
+	server, err := client.StartAndWait(ctx, "alice", "lab", nil, &api.WaitOptions{UseProgress: true, Timeout: 5 * time.Second})
+	if err != nil || !server.Ready || server.Name != "lab" {
+		t.Fatalf("Expected named server to be ready, got %+v %v", server, err)
+	}
+	if err := client.RemoveUserNamedServer(ctx, "alice", "lab"); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	users, err := client.ListUsers(ctx, &api.ListUsersParams{NameFilter: "alice", IncludeStoppedServers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := (*users)[0].Servers["lab"]; ok {
+		t.Errorf("Expected named server to be removed, got %+v", (*users)[0].Servers)
+	}
+	if _, ok := (*users)[0].Servers[""]; !ok {
+		t.Errorf("Expected stopped default server to be listed")
+	}
+
+	hub.FailSpawn("alice", "gpu", "out of GPUs")
+	_, err = client.StartAndWait(ctx, "alice", "gpu", nil, &api.WaitOptions{UseProgress: true})
+	if !errors.Is(err, api.ErrSpawnFailed) {
+		t.Errorf("Expected ErrSpawnFailed, got %v", err)
+	}
+}
+
+func TestStartAndWaitStopOnCancel(t *testing.T) {
+	hub := NewServer(&Config{SpawnDelay: 200 * time.Millisecond})
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	hub.AddUser("alice", false)
+	ctx := context.Background()
+
+	wait := &api.WaitOptions{Timeout: 50 * time.Millisecond, PollInterval: 10 * time.Millisecond, StopOnCancel: true}
+	_, err := client.StartAndWait(ctx, "alice", "", nil, wait)
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("Expected only context.DeadlineExceeded, got %v", err)
+	}
+	user, err := client.GetUser(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if server, ok := user.Servers[""]; ok && (server.Ready || server.Pending != api.PendingNone) {
+		t.Errorf("Expected the half-spawned server to be stopped, got %+v", server)
+	}
+}
+
+func TestImmediateServers(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	hub.AddUser("alice", false)
+	client, err := hub.Client(hub.AddToken("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := client.StartUserNamedServer(ctx, "alice", "lab", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StopUserNamedServer(ctx, "alice", "lab"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartUserServer(ctx, "alice", nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StopUserServer(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.StartUserServer(ctx, "bob", nil); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden starting another user's server, got %v", err)
+	}
+	if err := client.StartUserServer(ctx, "alice", nil); err != nil {
+		t.Fatal(err)
+	}
+	stopper, err := hub.Client(hub.AddToken("alice", "delete:servers!user=alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result, err := stopper.StopServer(ctx, "alice", "", false); err != nil || result != api.StopResultStopped {
+		t.Errorf("Expected a delete:servers token to stop the server, got %v %v", result, err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	hub.AddUser("alice", false)
+	client, err := hub.Client(hub.AddToken("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	created, err := client.CreateUserToken(ctx, "alice", &api.CreateUserTokenBody{Note: "ci", Scopes: []string{"read:users!user=alice"}, ExpiresIn: 3600})
+	if err != nil || created.Token == "" || created.ExpiresAt == nil {
+		t.Fatalf("Unexpected token %+v %v", created, err)
+	}
+	tokens, err := client.ListUserTokens(ctx, "alice")
+	if err != nil || len(*tokens) != 2 {
+		t.Fatalf("Expected 2 tokens, got %v %v", tokens, err)
+	}
+	token, err := client.GetUserToken(ctx, "alice", created.Id)
+	if err != nil || token.Note != "ci" {
+		t.Errorf("Unexpected token %+v %v", token, err)
+	}
+
+	identity, err := client.AuthenticateToken(ctx, created.Token)
+	if err != nil || identity.Name != "alice" || len(identity.Scopes) == 0 {
+		t.Errorf("Unexpected identity %+v %v", identity, err)
+	}
+	if identity, err := client.ValidateToken(ctx, created.Token); err != nil || identity.Name != "alice" {
+		t.Errorf("Unexpected identity %+v %v", identity, err)
+	}
+	restricted, _ := hub.Client(created.Token)
+	if _, err := restricted.CreateUserToken(ctx, "alice", &api.CreateUserTokenBody{}); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected restricted token to be unable to create tokens, got %v", err)
+	}
+	if newToken, err := client.NewAPIToken(ctx, &api.NewTokenBody{}); err != nil || newToken.Token == "" {
+		t.Errorf("Unexpected new token %+v %v", newToken, err)
+	}
+
+	if err := client.DeleteUserToken(ctx, "alice", created.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restricted.GetCurrentUser(ctx); !errors.Is(err, api.ErrForbidden) {
+		t.Errorf("Expected deleted token to be rejected, got %v", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	hub.AddUser("alice", false)
+	ctx := context.Background()
+
+	if _, err := client.CreateGroup(ctx, "physics"); err != nil {
+		t.Fatal(err)
+	}
+	group, err := client.AddGroupUsers(ctx, "physics", &api.AddGroupUsersBody{Users: []string{"alice", "admin"}})
+	if err != nil || len(group.Users) != 2 {
+		t.Fatalf("Unexpected group %+v %v", group, err)
+	}
+	if _, err := client.AddGroupUsers(ctx, "physics", &api.AddGroupUsersBody{Users: []string{"nobody"}}); !errors.Is(err, api.ErrBadRequest) {
+		t.Errorf("Expected ErrBadRequest adding an unknown user, got %v", err)
+	}
+	if err := client.RemoveGroupUsers(ctx, "physics", &api.RemoveGroupUsersBody{Users: []string{"admin"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.SetGroupProperties(ctx, "physics", map[string]interface{}{"quota": 10, "labels": map[string]string{"a": "b"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PatchGroupProperties(ctx, "physics", map[string]interface{}{"labels": map[string]interface{}{"a": nil, "c": "d"}}); err != nil {
+		t.Fatal(err)
+	}
+	properties, err := api.GetGroupProperties[struct {
+		Quota  int               `json:"quota"`
+		Labels map[string]string `json:"labels"`
+	}](ctx, client, "physics")
+	if err != nil || properties.Quota != 10 || len(properties.Labels) != 1 || properties.Labels["c"] != "d" {
+		t.Errorf("Unexpected properties %+v %v", properties, err)
+	}
+
+	user, err := client.GetUser(ctx, "alice")
+	if err != nil || len(user.Groups) != 1 || user.Groups[0] != "physics" {
+		t.Errorf("Expected alice to be in physics, got %+v %v", user, err)
+	}
+
+	client.CreateGroup(ctx, "chemistry")
+	groups, err := client.ListAllGroups(ctx, &api.ListGroupsParams{Limit: 1})
+	if err != nil || len(groups) != 2 {
+		t.Errorf("Expected 2 groups, got %v %v", groups, err)
+	}
+	if err := client.DeleteGroup(ctx, "chemistry"); err != nil {
+		t.Fatal(err)
+	}
+	if listed, err := client.ListGroups(ctx, &api.ListGroupsParams{}); err != nil || len(*listed) != 1 {
+		t.Errorf("Expected 1 group after delete, got %v %v", listed, err)
+	}
+	if _, err := client.GetGroup(ctx, "chemistry"); !errors.Is(err, api.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a deleted group, got %v", err)
+	}
+}
+
+func TestServicesAndProxy(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	hub.AddService("reports", "")
+	hub.AddService("billing", "")
+	ctx := context.Background()
+
+	services, err := client.ListServices(ctx)
+	if err != nil || len(*services) != 2 || (*services)[0].Name != "billing" {
+		t.Errorf("Unexpected services %+v %v", services, err)
+	}
+	service, err := client.GetService(ctx, "reports")
+	if err != nil || service.Prefix != "/services/reports/" {
+		t.Errorf("Unexpected service %+v %v", service, err)
+	}
+
+	table, err := client.GetProxyTable(ctx, nil)
+	if _, ok := (*table)["/"]; err != nil || !ok {
+		t.Errorf("Expected the hub route, got %v %v", table, err)
+	}
+	if err := client.ForceProxySync(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.NotifyNewProxy(ctx, &api.NotifyNewProxyBody{Ip: "127.0.0.1", Port: "8001"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Shutdown(ctx, &api.ShutdownBody{Servers: true}); err != nil {
+		t.Fatal(err)
+	}
+	if !hub.ShutdownRequested() {
+		t.Errorf("Expected shutdown to be recorded")
+	}
+}
+
+func TestFail(t *testing.T) {
+	hub := NewServer(nil)
+	defer hub.Close()
+	client := newAdminClient(t, hub)
+	ctx := context.Background()
+	retry := api.WithRetryPolicy(&api.RetryPolicy{MaxAttempts: 2, RetryStatusCodes: []int{http.StatusServiceUnavailable}})
+
+	hub.Fail(http.MethodGet, "users/admin", http.StatusServiceUnavailable, 1)
+	if _, err := client.GetUser(ctx, "admin", retry); err != nil {
+		t.Errorf("Expected the retry to succeed, got %v", err)
+	}
+
+	hub.Fail("", "info", http.StatusInternalServerError, 0)
+	_, err := client.GetInfo(ctx, retry)
+	if !errors.Is(err, api.ErrServerError) || api.Attempts(err) != 1 {
+		t.Errorf("Expected a single failed attempt, got %v", err)
+	}
+}
+
+func TestOAuth(t *testing.T) {
+	hub := NewServer(&Config{OAuthTokenExpiresIn: time.Hour})
+	defer hub.Close()
+	hub.AddUser("alice", false)
+
+	mux := http.NewServeMux()
+	service := httptest.NewServer(mux)
+	defer service.Close()
+	callbackURL := service.URL + "/services/reports/oauth_callback"
+	serviceToken := hub.AddService("reports", callbackURL)
+
+	client, err := api.CreateClient(&api.ClientConfig{
+		ApiToken:          serviceToken,
+		ApiURL:            hub.ApiURL(),
+		Host:              hub.URL,
+		BaseURL:           "/",
+		ServiceName:       "reports",
+		ServicePrefix:     "/services/reports/",
+		OAuthCallbackURL:  callbackURL,
+		OAuthAccessScopes: []string{"access:services!service=reports"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oauth, err := api.CreateHubOAuth(&api.HubOAuthConfig{Client: client})
+	if err != nil {
+		t.Fatal(err)
+	}
+	mux.Handle(oauth.CallbackPath(), oauth.CallbackHandler())
+	mux.Handle("/services/reports/", oauth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		identity, _ := api.IdentityFromContext(r.Context())
+		w.Write([]byte(identity.Name))
+	})))
+
+	jar, _ := cookiejar.New(nil)
+	browser := &http.Client{Jar: jar}
+	get := func() (int, string) {
+		request, _ := http.NewRequest(http.MethodGet, service.URL+"/services/reports/", nil)
+		request.Header.Set("Accept", "text/html")
+		response, err := browser.Do(request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer response.Body.Close()
+		body, _ := io.ReadAll(response.Body)
+		return response.StatusCode, string(body)
+	}
+
+	if status, _ := get(); status != http.StatusForbidden {
+		t.Errorf("Expected access to be denied while logged out, got %d", status)
+	}
+
+	hub.LoginAs("alice")
+	if status, body := get(); status != http.StatusOK || body != "alice" {
+		t.Errorf("Expected alice to be logged in, got %d %s", status, body)
+	}
+}
+
+func ptr[T any](value T) *T {
+	return &value
+}
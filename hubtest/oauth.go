@@ -0,0 +1,193 @@
+package hubtest
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+)
+
+const oauthCodeLifetime = 10 * time.Minute
+
+type oauthCode struct {
+	client      string
+	user        string
+	redirectURI string
+	challenge   string
+	method      string
+	scopes      []string
+	expires     time.Time
+}
+
+func (h *Hub) serveOAuth(w http.ResponseWriter, r *http.Request, segments []string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch {
+	case len(segments) == 1 && segments[0] == "authorize" && r.Method == http.MethodGet:
+		h.authorize(w, r)
+	case len(segments) == 1 && segments[0] == "token" && r.Method == http.MethodPost:
+		h.issueOAuthToken(w, r)
+	default:
+		writeError(w, http.StatusNotFound, "Not Found")
+	}
+}
+
+// oauthClient returns the service registered as the OAuth client clientId.
+func (h *Hub) oauthClient(clientId string) (*service, bool) {
+	if !strings.HasPrefix(clientId, "service-") {
+		return nil, false
+	}
+	s, ok := h.services[strings.TrimPrefix(clientId, "service-")]
+	return s, ok
+}
+
+// authorize grants the logged in user's authorization immediately, as the
+// hub does for services, and redirects back with a code.
+func (h *Hub) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	clientId := query.Get("client_id")
+	s, ok := h.oauthClient(clientId)
+	if !ok {
+		writeError(w, http.StatusBadRequest, "Invalid client_id parameter value.")
+		return
+	}
+	redirectURI := query.Get("redirect_uri")
+	redirect, err := url.Parse(redirectURI)
+	if redirectURI == "" || err != nil || (s.redirectURI != "" && redirectURI != s.redirectURI) {
+		writeError(w, http.StatusBadRequest, "Mismatching redirect URI.")
+		return
+	}
+
+	params := redirect.Query()
+	params.Set("state", query.Get("state"))
+	fail := func(code string, description string) {
+		params.Set("error", code)
+		params.Set("error_description", description)
+		redirect.RawQuery = params.Encode()
+		http.Redirect(w, r, redirect.String(), http.StatusFound)
+	}
+
+	if query.Get("response_type") != "code" {
+		fail("unsupported_response_type", "Only the code response type is supported")
+		return
+	}
+	challenge := query.Get("code_challenge")
+	method := query.Get("code_challenge_method")
+	if challenge != "" && method == "" {
+		method = "plain"
+	}
+	if method != "" && method != api.PKCEMethodS256 && method != "plain" {
+		fail("invalid_request", "Transform algorithm not supported")
+		return
+	}
+	if _, ok := h.users[h.loggedIn]; !ok {
+		fail("access_denied", "No user is logged in")
+		return
+	}
+
+	scopes := strings.Fields(query.Get("scope"))
+	if len(scopes) == 0 {
+		scopes = []string{"access:services!service=" + s.name}
+	}
+	code := randomHex()
+	h.codes[code] = &oauthCode{
+		client:      clientId,
+		user:        h.loggedIn,
+		redirectURI: redirectURI,
+		challenge:   challenge,
+		method:      method,
+		scopes:      scopes,
+		expires:     time.Now().Add(oauthCodeLifetime),
+	}
+	params.Set("code", code)
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (h *Hub) issueOAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	clientId := r.PostForm.Get("client_id")
+	s, ok := h.oauthClient(clientId)
+	if !ok || !h.isServiceToken(s.name, r.PostForm.Get("client_secret")) {
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", "Client authentication failed")
+		return
+	}
+
+	var user string
+	var scopes []string
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, ok := h.codes[r.PostForm.Get("code")]
+		delete(h.codes, r.PostForm.Get("code"))
+		if !ok || code.client != clientId || time.Now().After(code.expires) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired code")
+			return
+		}
+		if code.redirectURI != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Mismatching redirect URI")
+			return
+		}
+		if !verifyChallenge(code.challenge, code.method, r.PostForm.Get("code_verifier")) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Code verifier does not match challenge")
+			return
+		}
+		user, scopes = code.user, code.scopes
+	case "refresh_token":
+		previous, ok := h.refreshTokens[r.PostForm.Get("refresh_token")]
+		delete(h.refreshTokens, r.PostForm.Get("refresh_token"))
+		if !ok || previous.oauthClient != clientId {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+			return
+		}
+		delete(h.tokens, previous.value)
+		user, scopes = previous.user, previous.scopes
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "Unsupported grant type")
+		return
+	}
+
+	t := h.issueToken(&token{user: user, oauthClient: clientId, scopes: scopes, note: "OAuth token for " + clientId})
+	response := map[string]interface{}{
+		"access_token": t.value,
+		"token_type":   "Bearer",
+		"scope":        strings.Join(scopes, " "),
+	}
+	if h.config.OAuthTokenExpiresIn > 0 {
+		expiresAt := t.created.Add(h.config.OAuthTokenExpiresIn)
+		t.expiresAt = &expiresAt
+		response["expires_in"] = int(h.config.OAuthTokenExpiresIn / time.Second)
+	}
+	refreshToken := randomHex()
+	h.refreshTokens[refreshToken] = t
+	response["refresh_token"] = refreshToken
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Hub) isServiceToken(serviceName string, secret string) bool {
+	t, ok := h.tokens[secret]
+	return ok && t.service == serviceName && t.oauthClient == ""
+}
+
+func verifyChallenge(challenge string, method string, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if method == api.PKCEMethodS256 {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+// writeOAuthError writes an OAuth 2.0 error response (RFC 6749 section 5.2).
+func writeOAuthError(w http.ResponseWriter, status int, code string, description string) {
+	writeJSON(w, status, map[string]interface{}{"error": code, "error_description": description})
+}
@@ -0,0 +1,352 @@
+package hubtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/api"
+	"github.com/costrouc/go-jupyterhub-api/scope"
+)
+
+type serverKey struct {
+	user string
+	name string
+}
+
+type server struct {
+	name         string
+	pending      api.PendingAction
+	ready        bool
+	remove       bool
+	started      *time.Time
+	lastActivity *time.Time
+	spawnStarted time.Time
+	userOptions  interface{}
+	state        interface{}
+	failure      string
+	timer        *time.Timer
+}
+
+func (s *server) active() bool {
+	return s.ready || s.pending != api.PendingNone
+}
+
+func (s *server) cancel() {
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+}
+
+func serverURL(username string, serverName string) string {
+	if serverName == "" {
+		return "/user/" + url.PathEscape(username) + "/"
+	}
+	return "/user/" + url.PathEscape(username) + "/" + url.PathEscape(serverName) + "/"
+}
+
+func progressURL(username string, serverName string) string {
+	if serverName == "" {
+		return "/hub/api/users/" + url.PathEscape(username) + "/server/progress"
+	}
+	return "/hub/api/users/" + url.PathEscape(username) + "/servers/" + url.PathEscape(serverName) + "/progress"
+}
+
+// logName names a server in error messages the way JupyterHub does.
+func logName(username string, serverName string) string {
+	if serverName == "" {
+		return username
+	}
+	return username + ":" + serverName
+}
+
+func (h *Hub) serverModel(c *caller, u *user, s *server) api.JupyterHubServer {
+	model := api.JupyterHubServer{
+		Name:         s.name,
+		Ready:        s.ready,
+		Stopped:      !s.active(),
+		Pending:      s.pending,
+		Url:          serverURL(u.name, s.name),
+		ProgressUrl:  progressURL(u.name, s.name),
+		Started:      s.started,
+		LastActivity: s.lastActivity,
+		UserOptions:  s.userOptions,
+	}
+	if c.scopes.Allows("admin:server_state", scope.Server(u.name, s.name, h.userGroups(u.name)...)) {
+		model.State = s.state
+	}
+	return model
+}
+
+func (h *Hub) serveServer(w http.ResponseWriter, r *http.Request, c *caller, username string, serverName string) {
+	switch r.Method {
+	case http.MethodPost:
+		h.startServer(w, r, c, username, serverName)
+	case http.MethodDelete:
+		h.stopServer(w, r, c, username, serverName)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "Method Not Allowed")
+	}
+}
+
+func (h *Hub) startServer(w http.ResponseWriter, r *http.Request, c *caller, username string, serverName string) {
+	if !c.require(w, "servers", scope.Server(username, serverName, h.userGroups(username)...)) {
+		return
+	}
+	u, ok := h.users[username]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+username)
+		return
+	}
+	var options interface{}
+	if err := decodeBody(r, &options); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if options == nil {
+		options = map[string]interface{}{}
+	}
+
+	if s, ok := u.servers[serverName]; ok {
+		if s.pending != api.PendingNone {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is pending %s, please wait", logName(username, serverName), s.pending))
+			return
+		}
+		if s.ready {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is already running", logName(username, serverName)))
+			return
+		}
+	}
+
+	s, ok := u.servers[serverName]
+	if !ok {
+		s = &server{name: serverName}
+		u.servers[serverName] = s
+	}
+	now := time.Now()
+	s.userOptions = options
+	s.started = &now
+	s.lastActivity = &now
+	s.spawnStarted = now
+	s.failure = ""
+
+	key := serverKey{username, serverName}
+	failure, fail := h.spawnFailures[key]
+	delete(h.spawnFailures, key)
+
+	if h.config.SpawnDelay <= 0 {
+		if fail {
+			s.failure = failure
+			s.started = nil
+			writeError(w, http.StatusInternalServerError, "Spawn failed: "+failure)
+			return
+		}
+		h.finishSpawn(u, s)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	s.pending = api.PendingSpawn
+	s.timer = time.AfterFunc(h.config.SpawnDelay, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if s.pending != api.PendingSpawn {
+			return
+		}
+		s.timer = nil
+		if fail {
+			s.pending = api.PendingNone
+			s.failure = failure
+			s.started = nil
+			return
+		}
+		h.finishSpawn(u, s)
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Hub) finishSpawn(u *user, s *server) {
+	h.nextId++
+	s.pending = api.PendingNone
+	s.ready = true
+	s.state = map[string]interface{}{"pid": 10000 + h.nextId}
+	h.addRoute(u, s)
+}
+
+func (h *Hub) addRoute(u *user, s *server) {
+	spec := serverURL(u.name, s.name)
+	pid, _ := s.state.(map[string]interface{})["pid"].(int)
+	h.routes[spec] = api.JupyterHubProxyRoute{
+		RouteSpec: spec,
+		Target:    fmt.Sprintf("http://127.0.0.1:%d", pid),
+		Data:      map[string]interface{}{"user": u.name, "server_name": s.name},
+	}
+}
+
+func (h *Hub) stopServer(w http.ResponseWriter, r *http.Request, c *caller, username string, serverName string) {
+	if !c.require(w, "delete:servers", scope.Server(username, serverName, h.userGroups(username)...)) {
+		return
+	}
+	u, ok := h.users[username]
+	if !ok {
+		writeError(w, http.StatusNotFound, "No such user: "+username)
+		return
+	}
+	var body struct {
+		Remove bool `json:"remove"`
+	}
+	if err := decodeBody(r, &body); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if body.Remove && serverName == "" {
+		writeError(w, http.StatusBadRequest, "Cannot delete the default server")
+		return
+	}
+
+	s, ok := u.servers[serverName]
+	if !ok && serverName != "" {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("%s has no server named %s", username, serverName))
+		return
+	}
+	switch {
+	case ok && s.pending == api.PendingStop:
+		s.remove = s.remove || body.Remove
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case ok && s.pending == api.PendingSpawn:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is pending spawn, please wait", logName(username, serverName)))
+		return
+	case !ok || !s.ready:
+		if body.Remove {
+			delete(u.servers, serverName)
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	s.remove = body.Remove
+	if h.config.StopDelay <= 0 {
+		h.finishStop(u, s, s.remove)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	s.pending = api.PendingStop
+	s.timer = time.AfterFunc(h.config.StopDelay, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if s.pending == api.PendingStop {
+			h.finishStop(u, s, s.remove)
+		}
+	})
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (h *Hub) finishStop(u *user, s *server, remove bool) {
+	s.cancel()
+	if s.ready {
+		delete(h.routes, serverURL(u.name, s.name))
+	}
+	s.ready = false
+	s.pending = api.PendingNone
+	s.started = nil
+	s.state = nil
+	if remove && u.servers[s.name] == s {
+		delete(u.servers, s.name)
+	}
+}
+
+func (h *Hub) progressInterval() time.Duration {
+	interval := h.config.ProgressInterval
+	if interval <= 0 {
+		interval = h.config.SpawnDelay / 5
+	}
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+	return interval
+}
+
+// serveProgress streams spawn progress as server-sent events until the
+// server is ready or the spawn fails, like JupyterHub's SpawnProgressAPIHandler.
+func (h *Hub) serveProgress(w http.ResponseWriter, r *http.Request, c *caller, username string, serverName string) {
+	h.mu.Lock()
+	allowed := c.require(w, "read:servers", scope.Server(username, serverName, h.userGroups(username)...))
+	u, exists := h.users[username]
+	h.mu.Unlock()
+	if !allowed {
+		return
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, "No such user: "+username)
+		return
+	}
+
+	flusher, _ := w.(http.Flusher)
+	started := false
+	last := -1
+	for {
+		h.mu.Lock()
+		var snapshot server
+		s, ok := u.servers[serverName]
+		if ok {
+			snapshot = *s
+		}
+		h.mu.Unlock()
+
+		var event *api.ProgressEvent
+		terminal := true
+		switch {
+		case ok && snapshot.ready:
+			url := serverURL(username, serverName)
+			event = &api.ProgressEvent{
+				Progress:    100,
+				Ready:       true,
+				Message:     "Server ready at " + url,
+				HtmlMessage: fmt.Sprintf("Server ready at <a href=\"%s\">%s</a>", url, url),
+				Url:         url,
+			}
+		case ok && snapshot.pending == api.PendingSpawn:
+			terminal = false
+			progress := int(100 * time.Since(snapshot.spawnStarted) / h.config.SpawnDelay)
+			if progress > 99 {
+				progress = 99
+			}
+			if progress != last {
+				last = progress
+				event = &api.ProgressEvent{Progress: progress, Message: "Spawning server...", HtmlMessage: "Spawning server..."}
+			}
+		case ok && snapshot.failure != "":
+			event = &api.ProgressEvent{Progress: 100, Failed: true, Message: "Spawn failed: " + snapshot.failure}
+		case !started:
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is not starting...", logName(username, serverName)))
+			return
+		}
+
+		if event != nil {
+			if !started {
+				w.Header().Set("Content-Type", "text/event-stream")
+				w.Header().Set("Cache-Control", "no-cache")
+				w.WriteHeader(http.StatusOK)
+				started = true
+			}
+			data, _ := json.Marshal(event)
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if terminal {
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(h.progressInterval()):
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Package redact identifies the credentials carried by requests to and
+// responses from the JupyterHub API, so that they are never logged, returned
+// in error messages or written to fixtures.
+package redact
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Placeholder is written in place of a credential.
+const Placeholder = "[REDACTED]"
+
+// fields are JSON fields, form fields and query parameters whose values are
+// credentials.
+var fields = map[string]bool{
+	"token":         true,
+	"api_token":     true,
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"code":          true,
+	"code_verifier": true,
+	"password":      true,
+}
+
+// headers are request and response headers whose values are credentials.
+var headers = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+}
+
+// tokenPath precedes the token in the path of the token lookup endpoint.
+const tokenPath = "authorizations/token/"
+
+// Field reports whether the JSON field, form field or query parameter key
+// holds a credential.
+func Field(key string) bool {
+	return fields[key]
+}
+
+// Header reports whether the header key holds a credential.
+func Header(key string) bool {
+	return headers[http.CanonicalHeaderKey(key)]
+}
+
+// PathSecret returns the token in an authorizations/token/{token} path,
+// which may be relative to the API or a full URL.
+func PathSecret(path string) (string, bool) {
+	i := strings.Index(path, tokenPath)
+	if i == -1 {
+		return "", false
+	}
+	secret := path[i+len(tokenPath):]
+	if end := strings.IndexAny(secret, "/?#"); end != -1 {
+		secret = secret[:end]
+	}
+	return secret, secret != ""
+}
+
+// Path returns path, or an error message containing it, with the token of
+// an authorizations/token/{token} path replaced by Placeholder.
+func Path(path string) string {
+	secret, ok := PathSecret(path)
+	if !ok {
+		return path
+	}
+	return strings.ReplaceAll(path, tokenPath+secret, tokenPath+Placeholder)
+}
@@ -0,0 +1,18 @@
+package redact
+
+import "testing"
+
+func TestPath(t *testing.T) {
+	for path, expected := range map[string]string{
+		"authorizations/token/secret":                         "authorizations/token/[REDACTED]",
+		"http://hub:8081/hub/api/authorizations/token/secret": "http://hub:8081/hub/api/authorizations/token/[REDACTED]",
+		"authorizations/token/secret?x=1":                     "authorizations/token/[REDACTED]?x=1",
+		"authorizations/token":                                "authorizations/token",
+		"authorizations/token/":                               "authorizations/token/",
+		"users/alice":                                         "users/alice",
+	} {
+		if actual := Path(path); actual != expected {
+			t.Errorf("Expected %q to be redacted as %q, got %q", path, expected, actual)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+// Package replay records HTTP exchanges with a hub into fixture files and
+// serves them back, so that integration tests can run without a live
+// JupyterHub.
+//
+//	recorder := replay.NewRecorder(nil, &replay.Options{Secrets: []string{token}})
+//	client, err := api.CreateClient(&api.ClientConfig{ApiToken: token, Transport: recorder})
+//	...
+//	err = recorder.Save("testdata/scenario.json")
+//
+// and later, without the hub,
+//
+//	player, err := replay.Load("testdata/scenario.json", &replay.Options{Secrets: []string{token}})
+//	client, err := api.CreateClient(&api.ClientConfig{ApiToken: token, Transport: player})
+//
+// Tokens, passwords, client secrets and OAuth codes are replaced with
+// placeholders before fixtures are written, along with the Authorization
+// and cookie headers. Requests are replayed by matching their method, path,
+// query and body.
+package replay
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// ErrNoInteraction is returned by a Player for requests that were not
+// recorded.
+var ErrNoInteraction = errors.New("no recorded interaction matches request")
+
+// Options configures scrubbing. A Recorder and the Player loading its
+// fixtures should be given the same Options.
+type Options struct {
+	// Secrets are values, such as the client's API token, that are replaced
+	// wherever they appear in requests and responses.
+	Secrets []string
+	// Volatile are JSON fields of responses, such as timestamps, whose values
+	// change between recordings. A Recorder writes them with fixed values so
+	// that recording an unchanged hub again gives the same fixtures.
+	Volatile []string
+}
+
+type fixture struct {
+	Interactions []interaction `json:"interactions"`
+}
+
+type interaction struct {
+	Request  recordedRequest  `json:"request"`
+	Response recordedResponse `json:"response"`
+}
+
+type recordedRequest struct {
+	Method      string          `json:"method"`
+	Path        string          `json:"path"`
+	Query       string          `json:"query,omitempty"`
+	ContentType string          `json:"content_type,omitempty"`
+	JSON        json.RawMessage `json:"json,omitempty"`
+	Body        string          `json:"body,omitempty"`
+}
+
+type recordedResponse struct {
+	Status int             `json:"status"`
+	Header http.Header     `json:"header,omitempty"`
+	JSON   json.RawMessage `json:"json,omitempty"`
+	Body   string          `json:"body,omitempty"`
+}
+
+// body returns the bytes of a recorded body, which is stored as JSON when
+// it is a JSON document so that fixtures diff readably.
+func body(data json.RawMessage, text string) []byte {
+	if len(data) != 0 {
+		return data
+	}
+	return []byte(text)
+}
+
+// Recorder is an http.RoundTripper that sends requests through another
+// transport and records every exchange. Response bodies are read in full
+// before they are returned, so progress streams are delivered at once when
+// they end.
+type Recorder struct {
+	transport http.RoundTripper
+	scrub     *scrubber
+	volatile  map[string]bool
+
+	mu           sync.Mutex
+	interactions []interaction
+}
+
+// NewRecorder records requests sent through transport, or through
+// http.DefaultTransport when nil. A nil options uses the defaults.
+func NewRecorder(transport http.RoundTripper, options *Options) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	if options == nil {
+		options = &Options{}
+	}
+	volatile := map[string]bool{}
+	for _, key := range options.Volatile {
+		volatile[key] = true
+	}
+	return &Recorder{transport: transport, scrub: newScrubber(options.Secrets), volatile: volatile}
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if req.Body != nil {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	resp, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	responseBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(responseBody))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.interactions = append(r.interactions, interaction{
+		Request:  r.recordRequest(req, requestBody),
+		Response: r.recordResponse(resp, responseBody),
+	})
+	return resp, nil
+}
+
+func (r *Recorder) recordRequest(req *http.Request, data []byte) recordedRequest {
+	recorded := recordedRequest{
+		Method:      req.Method,
+		ContentType: req.Header.Get("Content-Type"),
+	}
+	// The body is scrubbed first so that secrets it introduces, such as a
+	// password, are also replaced in the path and query.
+	if strings.HasPrefix(recorded.ContentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(data)); err == nil {
+			recorded.Body = r.scrub.scrubValues(values).Encode()
+		} else {
+			recorded.Body = r.scrub.replace(string(data))
+		}
+	} else if scrubbed, ok := r.scrub.scrubJSON(data); ok {
+		recorded.JSON = scrubbed
+	} else {
+		recorded.Body = r.scrub.replace(string(data))
+	}
+	if secret, ok := redact.PathSecret(req.URL.EscapedPath()); ok {
+		r.scrub.placeholder(secret)
+	}
+	recorded.Query = r.scrub.scrubValues(req.URL.Query()).Encode()
+	recorded.Path = r.scrub.replace(req.URL.EscapedPath())
+	return recorded
+}
+
+func (r *Recorder) recordResponse(resp *http.Response, data []byte) recordedResponse {
+	recorded := recordedResponse{Status: resp.StatusCode, Header: http.Header{}}
+	if scrubbed, ok := r.scrub.scrubJSON(data); ok {
+		recorded.JSON = stabilize(scrubbed, r.volatile)
+	} else {
+		recorded.Body = r.scrub.replace(string(data))
+	}
+	for key, values := range resp.Header {
+		if droppedHeaders[key] || redact.Header(key) {
+			continue
+		}
+		for _, value := range values {
+			recorded.Header.Add(key, r.scrub.replace(value))
+		}
+	}
+	return recorded
+}
+
+// Save writes the recorded exchanges to a fixture file at path, creating its
+// directory if needed.
+func (r *Recorder) Save(path string) error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(fixture{Interactions: r.interactions}, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// Player is an http.RoundTripper that answers requests from a fixture file
+// without contacting a hub. Identical requests are answered with the
+// recorded responses in order, repeating the last one once they run out,
+// so polling loops replay as they were recorded.
+type Player struct {
+	scrub *scrubber
+
+	mu           sync.Mutex
+	interactions []interaction
+	keys         []string
+	used         []bool
+}
+
+// Load reads a fixture file written by Recorder.Save. A nil options uses
+// the defaults.
+func Load(path string, options *Options) (*Player, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("replay: parsing %s: %w", path, err)
+	}
+	if options == nil {
+		options = &Options{}
+	}
+
+	p := &Player{
+		scrub:        newScrubber(options.Secrets),
+		interactions: f.Interactions,
+		keys:         make([]string, len(f.Interactions)),
+		used:         make([]bool, len(f.Interactions)),
+	}
+	for i, recorded := range f.Interactions {
+		query, err := url.ParseQuery(recorded.Request.Query)
+		if err != nil {
+			return nil, fmt.Errorf("replay: parsing %s: %w", path, err)
+		}
+		p.keys[i] = p.key(recorded.Request.Method, recorded.Request.Path, query, recorded.Request.ContentType, body(recorded.Request.JSON, recorded.Request.Body))
+	}
+	return p, nil
+}
+
+// key identifies a request for matching. The token of a token lookup is
+// left out of its path so that lookups match whichever token they carry.
+func (p *Player) key(method string, path string, query url.Values, contentType string, data []byte) string {
+	path = redact.Path(p.scrub.replace(path))
+	return strings.Join([]string{method, path, p.scrub.canonicalValues(query), p.scrub.canonicalBody(contentType, data)}, "\n")
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	var data []byte
+	if req.Body != nil {
+		var err error
+		data, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+	key := p.key(req.Method, req.URL.EscapedPath(), req.URL.Query(), req.Header.Get("Content-Type"), data)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	last := -1
+	for i := range p.interactions {
+		if p.keys[i] != key {
+			continue
+		}
+		last = i
+		if !p.used[i] {
+			break
+		}
+	}
+	if last == -1 {
+		return nil, fmt.Errorf("replay: %s %s: %w", req.Method, req.URL.RequestURI(), ErrNoInteraction)
+	}
+	p.used[last] = true
+
+	recorded := p.interactions[last].Response
+	header := recorded.Header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	responseBody := body(recorded.JSON, recorded.Body)
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(responseBody)),
+		ContentLength: int64(len(responseBody)),
+		Request:       req,
+	}, nil
+}
+
+// Unused returns the method and path of every recorded request that has not
+// been replayed, so tests can check that a scenario ran to completion.
+func (p *Player) Unused() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var unused []string
+	for i, recorded := range p.interactions {
+		if !p.used[i] {
+			unused = append(unused, recorded.Request.Method+" "+recorded.Request.Path)
+		}
+	}
+	return unused
+}
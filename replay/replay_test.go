@@ -0,0 +1,115 @@
+package replay
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func send(t *testing.T, transport http.RoundTripper, method string, url string, contentType string, body string) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer usertoken")
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(data)
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	activity := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Server", "TornadoServer/6.4.1")
+		w.Header().Set("X-JupyterHub-Version", "5.2.1")
+		http.SetCookie(w, &http.Cookie{Name: "jupyterhub-session-id", Value: "abc"})
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/hub/api/users/alice/tokens":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id": "a1", "token": "0123456789abcdef", "note": "ci"}`))
+		case r.URL.Path == "/hub/api/authorizations/token/0123456789abcdef":
+			w.Write([]byte(`{"kind": "user", "name": "alice"}`))
+		case r.URL.Path == "/hub/api/users/alice":
+			activity++
+			w.Write([]byte(`{"name": "alice", "activity": ` + string(rune('0'+activity)) + `, "last_activity": "` + time.Now().Format(time.RFC3339Nano) + `"}`))
+		case r.URL.Path == "/hub/api/oauth2/token":
+			r.ParseForm()
+			w.Write([]byte(`{"access_token": "oauth-access", "token_type": "Bearer"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status": 404, "message": "Not Found"}`))
+		}
+	}))
+	defer server.Close()
+
+	options := &Options{Secrets: []string{"usertoken"}, Volatile: []string{"last_activity", "note"}}
+	recorder := NewRecorder(nil, options)
+	send(t, recorder, http.MethodPost, server.URL+"/hub/api/users/alice/tokens", "application/json", `{"note": "ci", "expires_in": 60}`)
+	send(t, recorder, http.MethodGet, server.URL+"/hub/api/authorizations/token/0123456789abcdef", "", "")
+	send(t, recorder, http.MethodGet, server.URL+"/hub/api/users/alice?include_stopped_servers=1&a=b", "", "")
+	send(t, recorder, http.MethodGet, server.URL+"/hub/api/users/alice?include_stopped_servers=1&a=b", "", "")
+	send(t, recorder, http.MethodPost, server.URL+"/hub/api/oauth2/token", "application/x-www-form-urlencoded", "client_id=service-x&client_secret=hunter2&code=xyz&grant_type=authorization_code")
+
+	path := filepath.Join(t.TempDir(), "fixtures", "scenario.json")
+	if err := recorder.Save(path); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"usertoken", "0123456789abcdef", "hunter2", "xyz", "oauth-access", "jupyterhub-session-id", "Bearer usertoken", "TornadoServer", "5.2.1"} {
+		if strings.Contains(string(data), secret) {
+			t.Errorf("Expected %q to be scrubbed from fixture:\n%s", secret, data)
+		}
+	}
+	if strings.Count(string(data), `"last_activity": "2000-01-01T00:00:00Z"`) != 2 || strings.Count(string(data), `"note": "VOLATILE"`) != 1 {
+		t.Errorf("Expected volatile fields to be recorded with fixed values:\n%s", data)
+	}
+
+	player, err := Load(path, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status, body := send(t, player, http.MethodPost, "http://localhost:8000/hub/api/users/alice/tokens", "application/json", `{"expires_in": 60, "note": "ci"}`)
+	if status != http.StatusCreated || !strings.Contains(body, `"token": "REDACTED-2"`) {
+		t.Errorf("Unexpected response %d %s", status, body)
+	}
+	if status, _ := send(t, player, http.MethodGet, "http://localhost:8000/hub/api/authorizations/token/REDACTED-2", "", ""); status != http.StatusOK {
+		t.Errorf("Expected token lookup to replay, got %d", status)
+	}
+	for _, expected := range []string{`"activity": 1`, `"activity": 2`, `"activity": 2`} {
+		if _, body := send(t, player, http.MethodGet, "http://localhost:8000/hub/api/users/alice?a=b&include_stopped_servers=1", "", ""); !strings.Contains(body, expected) {
+			t.Errorf("Expected %s, got %s", expected, body)
+		}
+	}
+	if unused := player.Unused(); len(unused) != 1 || unused[0] != "POST /hub/api/oauth2/token" {
+		t.Errorf("Expected only the token exchange to be unused, got %v", unused)
+	}
+	if _, body := send(t, player, http.MethodPost, "http://localhost:8000/hub/api/oauth2/token", "application/x-www-form-urlencoded", "grant_type=authorization_code&code=other&client_secret=secret&client_id=service-x"); !strings.Contains(body, "REDACTED") {
+		t.Errorf("Expected form requests to match regardless of secrets, got %s", body)
+	}
+
+	req, _ := http.NewRequest(http.MethodDelete, "http://localhost:8000/hub/api/users/alice", nil)
+	if _, err := player.RoundTrip(req); !errors.Is(err, ErrNoInteraction) {
+		t.Errorf("Expected ErrNoInteraction, got %v", err)
+	}
+}
@@ -0,0 +1,186 @@
+package replay
+
+import (
+	"encoding/json"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/costrouc/go-jupyterhub-api/internal/redact"
+)
+
+// droppedHeaders are left out of fixtures because they change on every
+// request or with the version of the hub. Headers carrying credentials are
+// dropped as well.
+var droppedHeaders = map[string]bool{
+	"Date":                 true,
+	"Content-Length":       true,
+	"Server":               true,
+	"X-Jupyterhub-Version": true,
+}
+
+// fixedTime is recorded in place of volatile timestamps, so that they still
+// parse as times when replayed.
+const fixedTime = "2000-01-01T00:00:00Z"
+
+// scrubber replaces secrets with numbered placeholders. Values of secret
+// fields are remembered when first seen so that later requests carrying
+// them, such as a path containing a token, are scrubbed consistently.
+type scrubber struct {
+	placeholders map[string]string
+}
+
+func newScrubber(secrets []string) *scrubber {
+	s := &scrubber{placeholders: map[string]string{}}
+	for _, secret := range secrets {
+		s.placeholder(secret)
+	}
+	return s
+}
+
+func (s *scrubber) placeholder(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	p, ok := s.placeholders[secret]
+	if !ok {
+		p = "REDACTED-" + strconv.Itoa(len(s.placeholders)+1)
+		s.placeholders[secret] = p
+	}
+	return p
+}
+
+// replace substitutes every known secret in text, longest first.
+func (s *scrubber) replace(text string) string {
+	secrets := make([]string, 0, len(s.placeholders))
+	for secret := range s.placeholders {
+		secrets = append(secrets, secret)
+	}
+	sort.Slice(secrets, func(i, j int) bool {
+		return len(secrets[i]) > len(secrets[j])
+	})
+	for _, secret := range secrets {
+		text = strings.ReplaceAll(text, secret, s.placeholders[secret])
+	}
+	return text
+}
+
+// scrubString replaces the value of a secret field with its placeholder and
+// known secrets within any other value.
+func (s *scrubber) scrubString(value string, secret bool) string {
+	if secret {
+		return s.placeholder(value)
+	}
+	return s.replace(value)
+}
+
+// scrubJSON scrubs a JSON document, reporting false if body is not JSON.
+func (s *scrubber) scrubJSON(body []byte) (json.RawMessage, bool) {
+	var value interface{}
+	if len(body) == 0 || json.Unmarshal(body, &value) != nil {
+		return nil, false
+	}
+	data, err := json.Marshal(walkStrings(value, false, s.scrubString))
+	return data, err == nil
+}
+
+// scrubValues scrubs form fields or query parameters.
+func (s *scrubber) scrubValues(values url.Values) url.Values {
+	return mapValues(values, s.scrubString)
+}
+
+// canonicalValues encodes values sorted by key for matching, with secret
+// fields blanked so that requests match whatever secrets they carry.
+func (s *scrubber) canonicalValues(values url.Values) string {
+	return mapValues(values, s.blank).Encode()
+}
+
+// canonicalBody normalizes a request body for matching. JSON is re-encoded
+// with sorted keys and form bodies are sorted, both with secret fields
+// blanked and known secrets replaced.
+func (s *scrubber) canonicalBody(contentType string, body []byte) string {
+	if strings.HasPrefix(contentType, "application/x-www-form-urlencoded") {
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			return s.canonicalValues(values)
+		}
+	}
+	var value interface{}
+	if len(body) != 0 && json.Unmarshal(body, &value) == nil {
+		if data, err := json.Marshal(walkStrings(value, false, s.blank)); err == nil {
+			return string(data)
+		}
+	}
+	return s.replace(string(body))
+}
+
+func (s *scrubber) blank(value string, secret bool) string {
+	if secret {
+		return ""
+	}
+	return s.replace(value)
+}
+
+func mapValues(values url.Values, fn func(value string, secret bool) string) url.Values {
+	mapped := url.Values{}
+	for key, vs := range values {
+		for _, v := range vs {
+			mapped.Add(key, fn(v, redact.Field(key)))
+		}
+	}
+	return mapped
+}
+
+// walkStrings returns value with fn applied to every string in it. secret is
+// set for the values of secret fields.
+func walkStrings(value interface{}, secret bool, fn func(value string, secret bool) string) interface{} {
+	switch v := value.(type) {
+	case string:
+		return fn(v, secret)
+	case map[string]interface{}:
+		for key, field := range v {
+			v[key] = walkStrings(field, redact.Field(key), fn)
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = walkStrings(item, secret, fn)
+		}
+	}
+	return value
+}
+
+// stabilize replaces the string values of volatile fields in a JSON document,
+// timestamps with fixedTime and anything else with "VOLATILE".
+func stabilize(data json.RawMessage, volatile map[string]bool) json.RawMessage {
+	var value interface{}
+	if len(volatile) == 0 || json.Unmarshal(data, &value) != nil {
+		return data
+	}
+	stabilizeValue(value, volatile)
+	stabilized, err := json.Marshal(value)
+	if err != nil {
+		return data
+	}
+	return stabilized
+}
+
+func stabilizeValue(value interface{}, volatile map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if text, ok := field.(string); ok && volatile[key] {
+				v[key] = "VOLATILE"
+				if _, err := time.Parse(time.RFC3339Nano, text); err == nil {
+					v[key] = fixedTime
+				}
+				continue
+			}
+			stabilizeValue(field, volatile)
+		}
+	case []interface{}:
+		for _, item := range v {
+			stabilizeValue(item, volatile)
+		}
+	}
+}
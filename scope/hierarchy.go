@@ -0,0 +1,28 @@
+package scope
+
+// hierarchy maps each scope to the scopes it directly implies, following
+// scope_definitions in jupyterhub/scopes.py. Scopes without subscopes, such
+// as admin-ui and shutdown, imply nothing else.
+var hierarchy = map[string][]string{
+	"admin:users":    {"admin:auth_state", "users", "read:roles:users", "delete:users"},
+	"users":          {"read:users", "list:users", "users:activity"},
+	"list:users":     {"read:users:name"},
+	"read:users":     {"read:users:name", "read:users:groups", "read:users:activity"},
+	"users:activity": {"read:users:activity"},
+	"read:roles":     {"read:roles:users", "read:roles:services", "read:roles:groups"},
+	"admin:servers":  {"admin:server_state", "servers"},
+	"servers":        {"read:servers", "delete:servers"},
+	"read:servers":   {"read:users:name"},
+	"shares":         {"read:shares", "users:shares", "groups:shares"},
+	"read:shares":    {"read:users:shares", "read:groups:shares"},
+	"users:shares":   {"read:users:shares"},
+	"groups:shares":  {"read:groups:shares"},
+	"admin:groups":   {"groups", "read:roles:groups", "delete:groups"},
+	"groups":         {"read:groups", "list:groups"},
+	"list:groups":    {"read:groups:name"},
+	"read:groups":    {"read:groups:name"},
+	"admin:services": {"read:services", "list:services", "read:roles:services"},
+	"list:services":  {"read:services:name"},
+	"read:services":  {"read:services:name"},
+	"tokens":         {"read:tokens"},
+}
@@ -0,0 +1,260 @@
+// Package scope parses and evaluates JupyterHub RBAC scopes, such as
+// "read:users!group=physics", so that services can authorize requests
+// locally with the scopes returned by the hub.
+package scope
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	FilterUser    = "user"
+	FilterServer  = "server"
+	FilterGroup   = "group"
+	FilterService = "service"
+)
+
+var ErrInvalidScope = errors.New("invalid scope")
+
+// Filter restricts a scope to the resources of a single user, server, group
+// or service. The zero Filter matches every resource.
+type Filter struct {
+	Kind  string
+	Value string
+}
+
+func (f Filter) IsZero() bool {
+	return f.Kind == ""
+}
+
+func (f Filter) String() string {
+	if f.IsZero() {
+		return ""
+	}
+	return f.Kind + "=" + f.Value
+}
+
+// Scope is a single scope such as "access:servers!server=alice/lab".
+type Scope struct {
+	Name   string
+	Filter Filter
+}
+
+func (s Scope) String() string {
+	if s.Filter.IsZero() {
+		return s.Name
+	}
+	return s.Name + "!" + s.Filter.String()
+}
+
+const (
+	KindUser    = "user"
+	KindServer  = "server"
+	KindGroup   = "group"
+	KindService = "service"
+)
+
+// Resource is the object a scope is evaluated against, built with User,
+// Server, Group or Service. Groups lists the groups a user, or the owner of
+// a server, belongs to so that group filters apply to members and their
+// servers. The zero Resource stands for hub wide actions such as read:hub or
+// shutdown, which only unfiltered scopes allow.
+type Resource struct {
+	Kind   string
+	Name   string
+	User   string
+	Groups []string
+}
+
+func User(name string, groups ...string) Resource {
+	return Resource{Kind: KindUser, Name: name, Groups: groups}
+}
+
+// Server is the server named server owned by user, with an empty server
+// name for the default server.
+func Server(user string, server string, groups ...string) Resource {
+	return Resource{Kind: KindServer, Name: server, User: user, Groups: groups}
+}
+
+func Group(name string) Resource {
+	return Resource{Kind: KindGroup, Name: name}
+}
+
+func Service(name string) Resource {
+	return Resource{Kind: KindService, Name: name}
+}
+
+// Parse parses a scope with an optional "!kind=value" filter. Scope names
+// are not checked against the known scopes so that custom scopes and scopes
+// added by newer hubs are accepted.
+func Parse(raw string) (Scope, error) {
+	name, filter, hasFilter := strings.Cut(raw, "!")
+	if name == "" || strings.ContainsAny(name, " =") {
+		return Scope{}, fmt.Errorf("%w %q: missing or malformed name", ErrInvalidScope, raw)
+	}
+	if !hasFilter {
+		return Scope{Name: name}, nil
+	}
+
+	kind, value, ok := strings.Cut(filter, "=")
+	if !ok || value == "" {
+		return Scope{}, fmt.Errorf("%w %q: filter must be of the form kind=value", ErrInvalidScope, raw)
+	}
+	switch kind {
+	case FilterUser, FilterGroup, FilterService:
+	case FilterServer:
+		if !strings.Contains(value, "/") {
+			return Scope{}, fmt.Errorf("%w %q: server filter must be of the form user/server", ErrInvalidScope, raw)
+		}
+	default:
+		return Scope{}, fmt.Errorf("%w %q: unknown filter %q", ErrInvalidScope, raw, kind)
+	}
+	return Scope{Name: name, Filter: Filter{Kind: kind, Value: value}}, nil
+}
+
+// Set is an expanded set of scopes, mapping each scope name to the filters
+// it is held with. Use ParseSet or Expand to build one.
+type Set map[string][]Filter
+
+// ParseSet parses and expands scopes as returned in the scopes field of the
+// hub's /user endpoint.
+func ParseSet(raw []string) (Set, error) {
+	scopes := make([]Scope, 0, len(raw))
+	for _, r := range raw {
+		scope, err := Parse(r)
+		if err != nil {
+			return nil, err
+		}
+		scopes = append(scopes, scope)
+	}
+	return Expand(scopes...), nil
+}
+
+// Expand adds every scope implied by scopes through the scope hierarchy,
+// keeping each scope's filter, so that admin:users!group=physics also holds
+// read:users!group=physics.
+func Expand(scopes ...Scope) Set {
+	set := Set{}
+	for _, scope := range scopes {
+		set.expand(scope.Name, scope.Filter)
+	}
+	return set
+}
+
+func (s Set) expand(name string, filter Filter) {
+	if !s.add(name, filter) {
+		return
+	}
+	for _, subscope := range hierarchy[name] {
+		s.expand(subscope, filter)
+	}
+}
+
+// add records filter for name, returning false when it was already covered.
+func (s Set) add(name string, filter Filter) bool {
+	filters, ok := s[name]
+	if ok && len(filters) == 0 {
+		return false
+	}
+	if filter.IsZero() {
+		s[name] = nil
+		return true
+	}
+	for _, f := range filters {
+		if f == filter {
+			return false
+		}
+	}
+	s[name] = append(filters, filter)
+	return true
+}
+
+// Has reports whether name is held with any filter.
+func (s Set) Has(name string) bool {
+	_, ok := s[name]
+	return ok
+}
+
+// Allows reports whether the set allows action, a scope name such as
+// "read:servers", on resource.
+func (s Set) Allows(action string, resource Resource) bool {
+	filters, ok := s[action]
+	if !ok {
+		return false
+	}
+	if len(filters) == 0 {
+		return true
+	}
+	for _, filter := range filters {
+		if filter.matches(resource) {
+			return true
+		}
+	}
+	return false
+}
+
+// Covers reports whether the set holds scope, treating a filtered scope as
+// the resource named by its filter. For example access:servers!user=alice
+// covers access:servers!server=alice/lab.
+func (s Set) Covers(scope Scope) bool {
+	return s.Allows(scope.Name, scope.Filter.resource())
+}
+
+// Strings returns the scopes in the set in sorted order.
+func (s Set) Strings() []string {
+	var scopes []string
+	for name, filters := range s {
+		if len(filters) == 0 {
+			scopes = append(scopes, name)
+		}
+		for _, filter := range filters {
+			scopes = append(scopes, Scope{Name: name, Filter: filter}.String())
+		}
+	}
+	sort.Strings(scopes)
+	return scopes
+}
+
+func (f Filter) matches(resource Resource) bool {
+	switch f.Kind {
+	case FilterUser:
+		return (resource.Kind == KindUser && resource.Name == f.Value) ||
+			(resource.Kind == KindServer && resource.User == f.Value)
+	case FilterServer:
+		return resource.Kind == KindServer && resource.User+"/"+resource.Name == f.Value
+	case FilterGroup:
+		if resource.Kind == KindGroup {
+			return resource.Name == f.Value
+		}
+		if resource.Kind != KindUser && resource.Kind != KindServer {
+			return false
+		}
+		for _, group := range resource.Groups {
+			if group == f.Value {
+				return true
+			}
+		}
+		return false
+	case FilterService:
+		return resource.Kind == KindService && resource.Name == f.Value
+	}
+	return false
+}
+
+func (f Filter) resource() Resource {
+	switch f.Kind {
+	case FilterUser:
+		return User(f.Value)
+	case FilterServer:
+		user, server, _ := strings.Cut(f.Value, "/")
+		return Server(user, server)
+	case FilterGroup:
+		return Group(f.Value)
+	case FilterService:
+		return Service(f.Value)
+	}
+	return Resource{}
+}
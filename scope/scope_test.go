@@ -0,0 +1,123 @@
+package scope
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for raw, expected := range map[string]Scope{
+		"read:users":                      {Name: "read:users"},
+		"read:users!group=physics":        {Name: "read:users", Filter: Filter{Kind: FilterGroup, Value: "physics"}},
+		"access:servers!server=alice/lab": {Name: "access:servers", Filter: Filter{Kind: FilterServer, Value: "alice/lab"}},
+		"custom:reports:read":             {Name: "custom:reports:read"},
+	} {
+		scope, err := Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scope != expected || scope.String() != raw {
+			t.Errorf("Expected %q to parse as %+v, got %+v", raw, expected, scope)
+		}
+	}
+
+	for _, raw := range []string{"", "!user=alice", "read:users!user", "read:users!user=", "read:users!owner=alice", "access:servers!server=alice"} {
+		if _, err := Parse(raw); !errors.Is(err, ErrInvalidScope) {
+			t.Errorf("Expected %q to be rejected, got %v", raw, err)
+		}
+	}
+}
+
+func TestExpand(t *testing.T) {
+	set, err := ParseSet([]string{"admin:servers!group=physics", "users:activity"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"admin:server_state!group=physics",
+		"admin:servers!group=physics",
+		"delete:servers!group=physics",
+		"read:servers!group=physics",
+		"read:users:activity",
+		"read:users:name!group=physics",
+		"servers!group=physics",
+		"users:activity",
+	}
+	if !reflect.DeepEqual(set.Strings(), expected) {
+		t.Errorf("Expected %v, got %v", expected, set.Strings())
+	}
+
+	set, _ = ParseSet([]string{"read:users!user=alice", "admin:users"})
+	if filters := set["read:users"]; len(filters) != 0 {
+		t.Errorf("Expected unfiltered read:users to replace filtered scope, got %v", filters)
+	}
+
+	set, _ = ParseSet([]string{"admin-ui"})
+	if !reflect.DeepEqual(set.Strings(), []string{"admin-ui"}) {
+		t.Errorf("Expected admin-ui to have no subscopes, got %v", set.Strings())
+	}
+	for _, action := range []string{"shutdown", "admin:users", "list:users"} {
+		if set.Allows(action, Resource{}) {
+			t.Errorf("Expected admin-ui not to allow %s", action)
+		}
+	}
+
+	set, _ = ParseSet([]string{"admin:services"})
+	for _, action := range []string{"list:services", "read:services:name", "read:roles:services"} {
+		if !set.Has(action) {
+			t.Errorf("Expected admin:services to imply %s, got %v", action, set.Strings())
+		}
+	}
+
+	for list, name := range map[string]string{"list:users": "read:users:name", "list:groups": "read:groups:name", "list:services": "read:services:name"} {
+		set, _ = ParseSet([]string{list})
+		if expected := []string{list, name}; !reflect.DeepEqual(set.Strings(), expected) {
+			t.Errorf("Expected %s to imply %s, got %v", list, name, set.Strings())
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	set, err := ParseSet([]string{
+		"read:users!group=physics",
+		"access:servers!server=alice/lab",
+		"access:servers!user=bob",
+		"access:services!service=reports",
+		"read:hub",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		action   string
+		resource Resource
+		expected bool
+	}{
+		{"read:users", User("alice", "physics"), true},
+		{"read:users:name", User("alice", "physics"), true},
+		{"read:users", User("carol", "chemistry"), false},
+		{"read:users", Group("physics"), true},
+		{"access:servers", Server("alice", "lab"), true},
+		{"access:servers", Server("alice", ""), false},
+		{"access:servers", Server("bob", "anything"), true},
+		{"access:servers", User("alice"), false},
+		{"access:services", Service("reports"), true},
+		{"access:services", Service("billing"), false},
+		{"read:hub", Resource{}, true},
+		{"shutdown", Resource{}, false},
+	}
+	for _, c := range cases {
+		if actual := set.Allows(c.action, c.resource); actual != c.expected {
+			t.Errorf("Expected Allows(%v, %+v) to be %v", c.action, c.resource, c.expected)
+		}
+	}
+
+	if !set.Covers(Scope{Name: "access:servers", Filter: Filter{Kind: FilterServer, Value: "bob/lab"}}) {
+		t.Errorf("Expected access:servers!user=bob to cover bob's servers")
+	}
+	if set.Covers(Scope{Name: "access:services"}) {
+		t.Errorf("Expected filtered scope not to cover unfiltered scope")
+	}
+}
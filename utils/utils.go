@@ -1,13 +1,33 @@
 package utils
 
-import "math/rand"
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"math/big"
+)
 
 var letters = []rune("abcdefghijklmnopqrstuvwxyz")
 
+// RandSeq returns n random lowercase letters read from crypto/rand.
 func RandSeq(n int) string {
 	b := make([]rune, n)
+	max := big.NewInt(int64(len(letters)))
 	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
+		index, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = letters[index.Int64()]
 	}
 	return string(b)
 }
+
+// RandomToken returns nbytes of crypto/rand randomness encoded as unpadded
+// URL safe base64, suitable for OAuth state values and secrets.
+func RandomToken(nbytes int) (string, error) {
+	b := make([]byte, nbytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}